@@ -177,6 +177,47 @@ func getContainersExport(srv *Server, version float64, w http.ResponseWriter, r
 	return nil
 }
 
+func getContainersArchive(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	name := vars["name"]
+	resource := r.Form.Get("path")
+	if resource == "" {
+		return fmt.Errorf("Bad parameter: path is required")
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	if err := srv.ContainerCopy(name, resource, w); err != nil {
+		utils.Debugf("%s", err)
+		return err
+	}
+	return nil
+}
+
+func putContainersArchive(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	name := vars["name"]
+	dst := r.Form.Get("path")
+	if dst == "" {
+		return fmt.Errorf("Bad parameter: path is required")
+	}
+
+	if err := srv.ContainerExtract(name, dst, r.Body); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
 func getImagesJSON(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return err
@@ -567,11 +608,13 @@ func deleteImages(srv *Server, version float64, w http.ResponseWriter, r *http.R
 }
 
 func postContainersStart(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	hostConfig := &HostConfig{}
+	// Without a host config, the container starts with its previous one
+	var hostConfig *HostConfig
 
 	// allow a nil body for backwards compatibility
 	if r.Body != nil {
 		if r.Header.Get("Content-Type") == "application/json" {
+			hostConfig = &HostConfig{}
 			if err := json.NewDecoder(r.Body).Decode(hostConfig); err != nil {
 				return err
 			}
@@ -589,6 +632,22 @@ func postContainersStart(srv *Server, version float64, w http.ResponseWriter, r
 	return nil
 }
 
+func postContainersUpdate(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	update := &UpdateConfig{}
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		return err
+	}
+	name := vars["name"]
+	if err := srv.ContainerUpdate(name, update); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 func postContainersStop(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return err
@@ -727,6 +786,73 @@ func getContainersByName(srv *Server, version float64, w http.ResponseWriter, r
 	return nil
 }
 
+func getVolumes(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	b, err := json.Marshal(srv.Volumes())
+	if err != nil {
+		return err
+	}
+	writeJSON(w, b)
+	return nil
+}
+
+func getVolumesByName(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	vol, err := srv.VolumeInspect(vars["name"])
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(vol)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, b)
+	return nil
+}
+
+func postVolumesCreate(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	config := &APIVolumeCreate{}
+	if err := json.NewDecoder(r.Body).Decode(config); err != nil {
+		return err
+	}
+	vol, err := srv.VolumeCreate(config.Name, config.Driver, config.DriverOpts)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(vol)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, b)
+	return nil
+}
+
+func postVolumesPrune(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	out, err := srv.VolumesPrune()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, b)
+	return nil
+}
+
+func deleteVolumes(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := srv.VolumeRemove(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 func getImagesByName(srv *Server, version float64, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
@@ -858,9 +984,12 @@ func createRouter(srv *Server, logging bool) (*mux.Router, error) {
 			"/containers/ps":                getContainersJSON,
 			"/containers/json":              getContainersJSON,
 			"/containers/{name:.*}/export":  getContainersExport,
+			"/containers/{name:.*}/archive": getContainersArchive,
 			"/containers/{name:.*}/changes": getContainersChanges,
 			"/containers/{name:.*}/json":    getContainersByName,
 			"/containers/{name:.*}/top":     getContainersTop,
+			"/volumes":                      getVolumes,
+			"/volumes/{name:.*}":            getVolumesByName,
 		},
 		"POST": {
 			"/auth":                         postAuth,
@@ -876,13 +1005,20 @@ func createRouter(srv *Server, logging bool) (*mux.Router, error) {
 			"/containers/{name:.*}/restart": postContainersRestart,
 			"/containers/{name:.*}/start":   postContainersStart,
 			"/containers/{name:.*}/stop":    postContainersStop,
+			"/containers/{name:.*}/update":  postContainersUpdate,
 			"/containers/{name:.*}/wait":    postContainersWait,
 			"/containers/{name:.*}/resize":  postContainersResize,
 			"/containers/{name:.*}/attach":  postContainersAttach,
+			"/volumes/create":               postVolumesCreate,
+			"/volumes/prune":                postVolumesPrune,
+		},
+		"PUT": {
+			"/containers/{name:.*}/archive": putContainersArchive,
 		},
 		"DELETE": {
 			"/containers/{name:.*}": deleteContainers,
 			"/images/{name:.*}":     deleteImages,
+			"/volumes/{name:.*}":    deleteVolumes,
 		},
 		"OPTIONS": {
 			"": optionsHandler,
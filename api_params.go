@@ -17,13 +17,19 @@ type APIImages struct {
 }
 
 type APIInfo struct {
-	Debug       bool
-	Containers  int
-	Images      int
-	NFd         int  `json:",omitempty"`
-	NGoroutines int  `json:",omitempty"`
-	MemoryLimit bool `json:",omitempty"`
-	SwapLimit   bool `json:",omitempty"`
+	Debug           bool
+	Containers      int
+	Images          int
+	ExecutionDriver string `json:",omitempty"`
+	NFd             int    `json:",omitempty"`
+	NGoroutines     int    `json:",omitempty"`
+	MemoryLimit     bool   `json:",omitempty"`
+	SwapLimit       bool   `json:",omitempty"`
+	CpuCfsQuota     bool   `json:",omitempty"`
+	Cpuset          bool   `json:",omitempty"`
+	BlkioWeight     bool   `json:",omitempty"`
+	BlkioThrottle   bool   `json:",omitempty"`
+	PidsLimit       bool   `json:",omitempty"`
 }
 
 type APITop struct {
@@ -85,3 +91,22 @@ type APIImageConfig struct {
 	ID string `json:"Id"`
 	*Config
 }
+
+type APIVolume struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Created    int64
+	UsedBy     []string
+}
+
+type APIVolumeCreate struct {
+	Name       string
+	Driver     string            `json:",omitempty"`
+	DriverOpts map[string]string `json:",omitempty"`
+}
+
+type APIVolumesPrune struct {
+	VolumesDeleted []string
+	SpaceReclaimed int64
+}
@@ -87,6 +87,9 @@ func TestGetInfo(t *testing.T) {
 	if infos.Images != len(initialImages) {
 		t.Errorf("Expected images: %d, %d found", len(initialImages), infos.Images)
 	}
+	if infos.ExecutionDriver != runtime.execDriver.Name() {
+		t.Errorf("Expected execution driver %s, %s found", runtime.execDriver.Name(), infos.ExecutionDriver)
+	}
 }
 
 func TestGetImagesJSON(t *testing.T) {
@@ -365,6 +368,110 @@ func TestGetContainersExport(t *testing.T) {
 	}
 }
 
+func TestGetContainersArchive(t *testing.T) {
+	runtime := mkRuntime(t)
+	defer nuke(runtime)
+
+	srv := &Server{runtime: runtime}
+
+	container, err := NewBuilder(runtime).Create(
+		&Config{
+			Image: GetTestImage(runtime).ID,
+			Cmd:   []string{"sh", "-c", "mkdir /test && echo hello > /test/foo"},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+
+	if err := container.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "/containers/"+container.ID+"/archive?path=/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRecorder()
+	if err := getContainersArchive(srv, APIVERSION, r, req, map[string]string{"name": container.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if r.Code != http.StatusOK {
+		t.Fatalf("%d OK expected, received %d\n", http.StatusOK, r.Code)
+	}
+
+	found := false
+	for tarReader := tar.NewReader(r.Body); ; {
+		h, err := tarReader.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+		if h.Name == "test/foo" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("The test file has not been found in the archive")
+	}
+
+	// Copying a missing path should fail
+	req, err = http.NewRequest("GET", "/containers/"+container.ID+"/archive?path=/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = httptest.NewRecorder()
+	if err := getContainersArchive(srv, APIVERSION, r, req, map[string]string{"name": container.ID}); err == nil {
+		t.Fatalf("Copying a missing path should fail")
+	}
+}
+
+func TestPutContainersArchive(t *testing.T) {
+	runtime := mkRuntime(t)
+	defer nuke(runtime)
+
+	srv := &Server{runtime: runtime}
+
+	container, err := NewBuilder(runtime).Create(
+		&Config{
+			Image: GetTestImage(runtime).ID,
+			Cmd:   []string{"cat", "/test/foo"},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+
+	context, err := mkBuildContext("", [][2]string{{"foo", "hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("PUT", "/containers/"+container.ID+"/archive?path=/test", context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRecorder()
+	if err := putContainersArchive(srv, APIVERSION, r, req, map[string]string{"name": container.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if r.Code != http.StatusOK {
+		t.Fatalf("%d OK expected, received %d\n", http.StatusOK, r.Code)
+	}
+
+	output, err := container.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "hello" {
+		t.Fatalf("Unexpected output: %s", output)
+	}
+}
+
 func TestGetContainersChanges(t *testing.T) {
 	runtime := mkRuntime(t)
 	defer nuke(runtime)
@@ -741,6 +848,62 @@ func TestPostContainersStart(t *testing.T) {
 	}
 }
 
+func TestPostContainersUpdate(t *testing.T) {
+	runtime := mkRuntime(t)
+	defer nuke(runtime)
+
+	srv := &Server{runtime: runtime}
+
+	container, err := NewBuilder(runtime).Create(
+		&Config{
+			Image:     GetTestImage(runtime).ID,
+			Cmd:       []string{"/bin/cat"},
+			CpuShares: 1024,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+
+	updateJSON, err := json.Marshal(&UpdateConfig{CpuShares: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", "/containers/"+container.ID+"/update", bytes.NewReader(updateJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRecorder()
+	if err := postContainersUpdate(srv, APIVERSION, r, req, map[string]string{"name": container.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if r.Code != http.StatusNoContent {
+		t.Fatalf("%d NO CONTENT expected, received %d\n", http.StatusNoContent, r.Code)
+	}
+
+	// The new limits are saved with the container
+	saved := &Container{root: container.root}
+	if err := saved.FromDisk(); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Config.CpuShares != 512 {
+		t.Fatalf("Expected 512 cpu shares, got %d", saved.Config.CpuShares)
+	}
+
+	updateJSON, err = json.Marshal(&UpdateConfig{BlkioWeight: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = http.NewRequest("POST", "/containers/"+container.ID+"/update", bytes.NewReader(updateJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := postContainersUpdate(srv, APIVERSION, httptest.NewRecorder(), req, map[string]string{"name": container.ID}); err == nil {
+		t.Fatalf("An invalid block IO weight should be refused")
+	}
+}
+
 func TestPostContainersStop(t *testing.T) {
 	runtime := mkRuntime(t)
 	defer nuke(runtime)
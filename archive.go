@@ -77,6 +77,19 @@ func (compression *Compression) Extension() string {
 	return ""
 }
 
+// Program returns the command line tool implementing the compression
+func (compression *Compression) Program() string {
+	switch *compression {
+	case Bzip2:
+		return "bzip2"
+	case Gzip:
+		return "gzip"
+	case Xz:
+		return "xz"
+	}
+	return ""
+}
+
 // Tar creates an archive from the directory at `path`, and returns it as a
 // stream of bytes.
 func Tar(path string, compression Compression) (io.Reader, error) {
@@ -86,7 +99,20 @@ func Tar(path string, compression Compression) (io.Reader, error) {
 // Tar creates an archive from the directory at `path`, only including files whose relative
 // paths are included in `filter`. If `filter` is nil, then all files are included.
 func TarFilter(path string, compression Compression, filter []string) (io.Reader, error) {
+	return tarCmd(path, compression, filter, nil)
+}
+
+// TarExclude creates an archive from the directory at `path`, leaving out the
+// files whose relative paths, like "./foo", are in `exclude`.
+func TarExclude(path string, compression Compression, exclude []string) (io.Reader, error) {
+	return tarCmd(path, compression, nil, exclude)
+}
+
+func tarCmd(path string, compression Compression, filter, exclude []string) (io.Reader, error) {
 	args := []string{"tar", "--numeric-owner", "-f", "-", "-C", path}
+	for _, e := range exclude {
+		args = append(args, "--exclude="+e)
+	}
 	if filter == nil {
 		filter = []string{"."}
 	}
@@ -135,6 +161,36 @@ func Untar(archive io.Reader, path string) error {
 	return nil
 }
 
+// CompressStream compresses `archive` with the given algorithm.
+func CompressStream(archive io.Reader, compression Compression) (io.Reader, error) {
+	if compression == Uncompressed {
+		return archive, nil
+	}
+	cmd := exec.Command(compression.Program(), "-c")
+	cmd.Stdin = archive
+	return CmdStream(cmd)
+}
+
+// DecompressStream returns the uncompressed content of `archive`, which may
+// be compressed with any of the algorithms supported by Untar.
+func DecompressStream(archive io.Reader) (io.Reader, error) {
+	buf := make([]byte, 10)
+	if _, err := io.ReadFull(archive, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("Tarball too short")
+		}
+		return nil, err
+	}
+	compression := DetectCompression(buf)
+	stream := io.MultiReader(bytes.NewReader(buf), archive)
+	if compression == Uncompressed {
+		return stream, nil
+	}
+	cmd := exec.Command(compression.Program(), "-d", "-c")
+	cmd.Stdin = stream
+	return CmdStream(cmd)
+}
+
 // TarUntar is a convenience function which calls Tar and Untar, with
 // the output of one piped into the other. If either Tar or Untar fails,
 // TarUntar aborts and returns the error.
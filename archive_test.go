@@ -116,3 +116,38 @@ func TestTarUntar(t *testing.T) {
 		}
 	}
 }
+
+func TestTarExclude(t *testing.T) {
+	origin, err := ioutil.TempDir("", "docker-test-tar-exclude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(origin)
+	for _, dir := range []string{".dockerinit", "nested/.dockerinit"} {
+		if err := os.MkdirAll(path.Join(origin, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(origin, dir, "file"), []byte("hello world"), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archive, err := TarExclude(origin, Uncompressed, []string{"./.dockerinit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, err := ioutil.TempDir("", "docker-test-tar-exclude-dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	if err := Untar(archive, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(tmp, ".dockerinit")); !os.IsNotExist(err) {
+		t.Fatalf("./.dockerinit should have been excluded: %v", err)
+	}
+	if _, err := os.Stat(path.Join(tmp, "nested/.dockerinit/file")); err != nil {
+		t.Fatalf("Only ./.dockerinit should have been excluded: %v", err)
+	}
+}
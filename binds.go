@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// A BindMap mounts a path of the host, or a named volume, in a container.
+// It is given as src:dst[:options], the options being a comma-separated
+// list of a mode (ro or rw), a SELinux relabeling (z or Z) and a mount
+// propagation mode.
+type BindMap struct {
+	SrcPath     string
+	DstPath     string
+	Mode        string // ro or rw
+	Relabel     string // z to share the source between containers, Z to keep it private
+	Propagation string // shared, slave or private, or their recursive variants rshared, rslave and rprivate
+}
+
+var bindPropagations = []string{"shared", "rshared", "slave", "rslave", "private", "rprivate"}
+
+// parseBind parses and validates a bind, so that `docker run` fails before
+// creating the container. The source must either exist on the host or be
+// the name of a volume.
+func parseBind(spec string) (*BindMap, error) {
+	arr := strings.Split(spec, ":")
+	if len(arr) != 2 && len(arr) != 3 {
+		return nil, fmt.Errorf("Invalid bind specification: %s. It needs to be of the form src:dst[:options].", spec)
+	}
+	bind := &BindMap{SrcPath: arr[0], DstPath: path.Clean(arr[1]), Mode: "rw"}
+
+	if !path.IsAbs(arr[1]) {
+		return nil, fmt.Errorf("Invalid bind destination: %s. It needs to be an absolute path.", arr[1])
+	}
+	if bind.DstPath == "/" {
+		return nil, fmt.Errorf("Illegal bind destination: %s", arr[1])
+	}
+	if path.IsAbs(bind.SrcPath) {
+		if _, err := os.Stat(bind.SrcPath); err != nil {
+			return nil, fmt.Errorf("Invalid bind source: %s", err)
+		}
+	} else if !validVolumeName.MatchString(bind.SrcPath) {
+		return nil, fmt.Errorf("Invalid bind source: %s. It needs to be an absolute path or the name of a volume.", bind.SrcPath)
+	}
+
+	if len(arr) == 2 {
+		return bind, nil
+	}
+	var mode string
+	for _, opt := range strings.Split(arr[2], ",") {
+		var option *string
+		switch opt {
+		case "ro", "rw":
+			option = &mode
+		case "z", "Z":
+			option = &bind.Relabel
+		default:
+			for _, propagation := range bindPropagations {
+				if opt == propagation {
+					option = &bind.Propagation
+				}
+			}
+		}
+		if option == nil {
+			return nil, fmt.Errorf("Invalid bind option: %s. Valid options are ro, rw, z, Z and %s.", opt, strings.Join(bindPropagations, ", "))
+		}
+		if *option != "" {
+			return nil, fmt.Errorf("Conflicting bind options: %s and %s", *option, opt)
+		}
+		*option = opt
+	}
+	if mode != "" {
+		bind.Mode = mode
+	}
+	return bind, nil
+}
+
+// checkBindSource checks, in the daemon, that the source of a bind exists and
+// that its mount can propagate mounts as the bind asks. The sources of named
+// volumes are only known once they are mounted.
+func checkBindSource(bind *BindMap) error {
+	if _, err := os.Stat(bind.SrcPath); err != nil {
+		return fmt.Errorf("Invalid bind source: %s", err)
+	}
+	switch bind.Propagation {
+	case "shared", "rshared", "slave", "rslave":
+		return checkMountPropagation(bind.SrcPath, bind.Propagation)
+	}
+	return nil
+}
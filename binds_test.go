@@ -0,0 +1,88 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParseBind(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-test-binds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for spec, expected := range map[string]BindMap{
+		tmp + ":/data":                  {tmp, "/data", "rw", "", ""},
+		tmp + ":/data/:ro":              {tmp, "/data", "ro", "", ""},
+		tmp + ":/data:Z":                {tmp, "/data", "rw", "Z", ""},
+		tmp + ":/data:ro,z,rslave":      {tmp, "/data", "ro", "z", "rslave"},
+		"data:/var/lib/data:shared,rw":  {"data", "/var/lib/data", "rw", "", "shared"},
+		"data.1:/var/lib/data:rprivate": {"data.1", "/var/lib/data", "rw", "", "rprivate"},
+	} {
+		bind, err := parseBind(spec)
+		if err != nil {
+			t.Fatalf("%s: %s", spec, err)
+		}
+		if *bind != expected {
+			t.Fatalf("%s: expected %v, got %v", spec, expected, *bind)
+		}
+	}
+
+	for _, spec := range []string{
+		tmp,
+		tmp + ":",
+		tmp + ":data",
+		tmp + ":/",
+		tmp + ":.",
+		tmp + "/nothing:/data",
+		"./data:/data",
+		tmp + ":/data:ro:rw",
+		tmp + ":/data:rwm",
+		tmp + ":/data:ro,rw",
+		tmp + ":/data:z,Z",
+		tmp + ":/data:shared,slave",
+	} {
+		if _, err := parseBind(spec); err == nil {
+			t.Fatalf("%s should be invalid", spec)
+		}
+	}
+}
+
+func TestParseRunBinds(t *testing.T) {
+	if _, _, _, err := ParseRun([]string{"-b", "/nothing", "_", "true"}, nil); err == nil {
+		t.Fatal("A bind without destination should be refused")
+	}
+	if _, _, _, err := ParseRun([]string{"-b", "/nothing:/data", "_", "true"}, nil); err == nil {
+		t.Fatal("A bind of a source which doesn't exist should be refused")
+	}
+	if _, _, _, err := ParseRun([]string{"-v", "data:/data:ro,Z", "_", "true"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	config, hostConfig, _, err := ParseRun([]string{"-b", "/tmp:/data/:ro,rshared", "_", "true"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := config.Volumes["/data"]; !exists {
+		t.Fatalf("Expected the destination of the bind in the volumes, got %v", config.Volumes)
+	}
+	if len(hostConfig.Binds) != 1 || hostConfig.Binds[0] != "/tmp:/data/:ro,rshared" {
+		t.Fatalf("Unexpected binds: %v", hostConfig.Binds)
+	}
+}
+
+func TestCheckBindSource(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-test-binds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := checkBindSource(&BindMap{SrcPath: tmp, DstPath: "/data", Mode: "rw", Propagation: "rprivate"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkBindSource(&BindMap{SrcPath: tmp + "/nothing", DstPath: "/data", Mode: "rw"}); err == nil {
+		t.Fatal("A bind of a source which doesn't exist should be refused")
+	}
+}
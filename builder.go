@@ -85,28 +85,9 @@ func (builder *Builder) Create(config *Config) (*Container, error) {
 		builder.runtime.Dns = defaultDns
 	}
 
-	// If custom dns exists, then create a resolv.conf for the container
-	if len(config.Dns) > 0 || len(builder.runtime.Dns) > 0 {
-		var dns []string
-		if len(config.Dns) > 0 {
-			dns = config.Dns
-		} else {
-			dns = builder.runtime.Dns
-		}
-		container.ResolvConfPath = path.Join(container.root, "resolv.conf")
-		f, err := os.Create(container.ResolvConfPath)
-		if err != nil {
-			return nil, err
-		}
-		defer f.Close()
-		for _, dns := range dns {
-			if _, err := f.Write([]byte("nameserver " + dns + "\n")); err != nil {
-				return nil, err
-			}
-		}
-	} else {
-		container.ResolvConfPath = "/etc/resolv.conf"
-	}
+	// The resolv.conf and /etc/hosts of the container are written when it starts
+	container.ResolvConfPath = path.Join(container.root, "resolv.conf")
+	container.HostsPath = path.Join(container.root, "hosts")
 
 	// Step 2: save the container json
 	if err := container.ToDisk(); err != nil {
@@ -122,6 +103,16 @@ func (builder *Builder) Create(config *Config) (*Container, error) {
 // Commit creates a new filesystem image from the current state of a container.
 // The image can optionally be tagged into a repository
 func (builder *Builder) Commit(container *Container, repository, tag, comment, author string, config *Config) (*Image, error) {
+	// A container which always ran read-only has nothing to commit
+	if container.readonlyRootfs() {
+		changes, err := container.Changes()
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) == 0 {
+			return nil, fmt.Errorf("Impossible to commit %s: its root filesystem is read-only and has no changes", container.ShortID())
+		}
+	}
 	// FIXME: freeze the container before copying it to avoid data corruption?
 	// FIXME: this shouldn't be in commands.
 	rwTar, err := container.ExportRw()
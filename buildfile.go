@@ -194,6 +194,26 @@ func (b *buildFile) CmdVolume(args string) error {
 	return nil
 }
 
+func (b *buildFile) CmdUser(args string) error {
+	if args == "" {
+		return fmt.Errorf("User cannot be empty")
+	}
+	b.config.User = args
+	return b.commit("", b.config.Cmd, fmt.Sprintf("USER %v", args))
+}
+
+func (b *buildFile) CmdWorkdir(workdir string) error {
+	if workdir == "" {
+		return fmt.Errorf("Workdir cannot be empty")
+	}
+	// Relative paths are relative to the previous working directory
+	if !path.IsAbs(workdir) {
+		workdir = path.Join("/", b.config.WorkingDir, workdir)
+	}
+	b.config.WorkingDir = path.Clean(workdir)
+	return b.commit("", b.config.Cmd, fmt.Sprintf("WORKDIR %s", b.config.WorkingDir))
+}
+
 func (b *buildFile) addRemote(container *Container, orig, dest string) error {
 	file, err := utils.Download(orig, ioutil.Discard)
 	if err != nil {
@@ -216,17 +236,17 @@ func (b *buildFile) addContext(container *Container, orig, dest string) error {
 		return err
 	}
 	if fi.IsDir() {
-		if err := CopyWithTar(origPath, destPath); err != nil {
+		if err := b.runtime.idMappings.CopyWithTar(origPath, destPath); err != nil {
 			return err
 		}
 		// First try to unpack the source as an archive
-	} else if err := UntarPath(origPath, destPath); err != nil {
+	} else if err := b.runtime.idMappings.UntarPath(origPath, destPath); err != nil {
 		utils.Debugf("Couldn't untar %s to %s: %s", origPath, destPath, err)
 		// If that fails, just copy it as a regular file
 		if err := os.MkdirAll(path.Dir(destPath), 0700); err != nil {
 			return err
 		}
-		if err := CopyWithTar(origPath, destPath); err != nil {
+		if err := b.runtime.idMappings.CopyWithTar(origPath, destPath); err != nil {
 			return err
 		}
 	}
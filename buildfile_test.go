@@ -96,6 +96,25 @@ CMD Hello world
 from %s
 VOLUME /test
 CMD Hello world
+`,
+		nil,
+	},
+
+	{
+		`
+from %s
+WORKDIR /test
+WORKDIR workdir
+run    [ "$(pwd)" = "/test/workdir" ]
+`,
+		nil,
+	},
+
+	{
+		`
+from %s
+USER daemon
+run    [ "$(id -u)" = "1" ]
 `,
 		nil,
 	},
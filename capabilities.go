@@ -0,0 +1,194 @@
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// capabilityNumbers maps the names of the linux capabilities, as used by
+// lxc.cap.drop, to their numbers
+var capabilityNumbers = map[string]int{
+	"chown":            0,
+	"dac_override":     1,
+	"dac_read_search":  2,
+	"fowner":           3,
+	"fsetid":           4,
+	"kill":             5,
+	"setgid":           6,
+	"setuid":           7,
+	"setpcap":          8,
+	"linux_immutable":  9,
+	"net_bind_service": 10,
+	"net_broadcast":    11,
+	"net_admin":        12,
+	"net_raw":          13,
+	"ipc_lock":         14,
+	"ipc_owner":        15,
+	"sys_module":       16,
+	"sys_rawio":        17,
+	"sys_chroot":       18,
+	"sys_ptrace":       19,
+	"sys_pacct":        20,
+	"sys_admin":        21,
+	"sys_boot":         22,
+	"sys_nice":         23,
+	"sys_resource":     24,
+	"sys_time":         25,
+	"sys_tty_config":   26,
+	"mknod":            27,
+	"lease":            28,
+	"audit_write":      29,
+	"audit_control":    30,
+	"setfcap":          31,
+	"mac_override":     32,
+	"mac_admin":        33,
+	"syslog":           34,
+	"wake_alarm":       35,
+	"block_suspend":    36,
+	"audit_read":       37,
+}
+
+// defaultDroppedCapabilities are removed from every container. They mainly
+// apply to the user root in the container.
+var defaultDroppedCapabilities = []string{
+	"audit_control",
+	"audit_write",
+	"mac_admin",
+	"mac_override",
+	"mknod",
+	"setfcap",
+	"setpcap",
+	"sys_admin",
+	"sys_boot",
+	"sys_module",
+	"sys_nice",
+	"sys_pacct",
+	"sys_rawio",
+	"sys_resource",
+	"sys_time",
+	"sys_tty_config",
+}
+
+// normalizeCapability accepts the names of the capabilities in any case and
+// with or without the CAP_ prefix, e.g. CAP_NET_ADMIN or net_admin
+func normalizeCapability(name string) string {
+	name = strings.ToLower(name)
+	return strings.TrimPrefix(name, "cap_")
+}
+
+// validateCapabilities checks a list of capabilities as given to -cap-add and
+// -cap-drop. "all" stands for every capability.
+func validateCapabilities(names []string) error {
+	for _, name := range names {
+		name = normalizeCapability(name)
+		if _, exists := capabilityNumbers[name]; !exists && name != "all" {
+			return fmt.Errorf("Unknown capability: %s", name)
+		}
+	}
+	return nil
+}
+
+// lastCapability returns the number of the last capability known to the
+// running kernel
+func lastCapability() int {
+	data, err := ioutil.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		// Kernels older than 3.2 don't tell, assume up to mac_admin
+		return capabilityNumbers["mac_admin"]
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return capabilityNumbers["mac_admin"]
+	}
+	return n
+}
+
+// allCapabilities returns the capabilities known to the running kernel,
+// ordered by number
+func allCapabilities() []string {
+	last := lastCapability()
+	names := []string{}
+	for name, n := range capabilityNumbers {
+		if n <= last {
+			names = append(names, name)
+		}
+	}
+	sort.Sort(capabilitiesByNumber(names))
+	return names
+}
+
+type capabilitiesByNumber []string
+
+func (c capabilitiesByNumber) Len() int      { return len(c) }
+func (c capabilitiesByNumber) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c capabilitiesByNumber) Less(i, j int) bool {
+	return capabilityNumbers[c[i]] < capabilityNumbers[c[j]]
+}
+
+// effectiveCapabilities returns the capabilities kept by a container started
+// with hostConfig. The default set is reduced by CapDrop, then extended by
+// CapAdd, so that "-cap-drop all -cap-add net_admin" keeps only net_admin.
+// Privileged containers keep everything.
+func effectiveCapabilities(hostConfig *HostConfig) ([]string, error) {
+	if err := validateCapabilities(hostConfig.CapAdd); err != nil {
+		return nil, err
+	}
+	if err := validateCapabilities(hostConfig.CapDrop); err != nil {
+		return nil, err
+	}
+	all := allCapabilities()
+	if hostConfig.Privileged {
+		return all, nil
+	}
+
+	dropped := make(map[string]bool)
+	for _, name := range defaultDroppedCapabilities {
+		dropped[name] = true
+	}
+	for _, name := range hostConfig.CapDrop {
+		if name = normalizeCapability(name); name == "all" {
+			for _, name := range all {
+				dropped[name] = true
+			}
+		} else {
+			dropped[name] = true
+		}
+	}
+	for _, name := range hostConfig.CapAdd {
+		if name = normalizeCapability(name); name == "all" {
+			dropped = make(map[string]bool)
+		} else {
+			delete(dropped, name)
+		}
+	}
+
+	kept := []string{}
+	for _, name := range all {
+		if !dropped[name] {
+			kept = append(kept, name)
+		}
+	}
+	return kept, nil
+}
+
+// droppedCapabilities returns the capabilities removed from the container,
+// i.e. those known to the kernel which are not in its effective set
+func droppedCapabilities(container *Container) []string {
+	if container.Capabilities == nil {
+		return defaultDroppedCapabilities
+	}
+	kept := make(map[string]bool)
+	for _, name := range container.Capabilities {
+		kept[name] = true
+	}
+	dropped := []string{}
+	for _, name := range allCapabilities() {
+		if !kept[name] {
+			dropped = append(dropped, name)
+		}
+	}
+	return dropped
+}
@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"testing"
+)
+
+func hasCapability(caps []string, name string) bool {
+	for _, c := range caps {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEffectiveCapabilities(t *testing.T) {
+	// The default set
+	caps, err := effectiveCapabilities(&HostConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCapability(caps, "sys_admin") || hasCapability(caps, "mknod") {
+		t.Fatalf("The default capabilities should not include sys_admin or mknod: %v", caps)
+	}
+	if !hasCapability(caps, "chown") || !hasCapability(caps, "net_bind_service") {
+		t.Fatalf("The default capabilities should include chown and net_bind_service: %v", caps)
+	}
+
+	// Adding and dropping, in any case and with or without prefix
+	caps, err = effectiveCapabilities(&HostConfig{CapAdd: []string{"SYS_ADMIN"}, CapDrop: []string{"CAP_CHOWN"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCapability(caps, "sys_admin") || hasCapability(caps, "chown") {
+		t.Fatalf("Expected sys_admin without chown, got %v", caps)
+	}
+
+	// Keeping only one capability
+	caps, err = effectiveCapabilities(&HostConfig{CapAdd: []string{"net_admin"}, CapDrop: []string{"all"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(caps) != 1 || caps[0] != "net_admin" {
+		t.Fatalf("Expected only net_admin, got %v", caps)
+	}
+
+	// Privileged containers keep everything
+	caps, err = effectiveCapabilities(&HostConfig{Privileged: true, CapDrop: []string{"all"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(caps) != len(allCapabilities()) {
+		t.Fatalf("Expected every capability, got %v", caps)
+	}
+	if dropped := droppedCapabilities(&Container{Capabilities: caps}); len(dropped) != 0 {
+		t.Fatalf("Nothing should be dropped from a privileged container, got %v", dropped)
+	}
+
+	if _, err := effectiveCapabilities(&HostConfig{CapAdd: []string{"fly"}}); err == nil {
+		t.Fatalf("Unknown capabilities should be refused")
+	}
+}
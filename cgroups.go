@@ -0,0 +1,302 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Parent cgroup of the containers run by the native driver
+const nativeCgroupRoot = "docker"
+
+// A cgroupSetting is a value written to a file of a cgroup subsystem, eg.
+// "memory.limit_in_bytes". The lxc driver renders them as lxc.cgroup.* lines
+// of the lxc config, the native driver writes them itself.
+type cgroupSetting struct {
+	Subsystem string
+	Key       string
+	Value     string
+}
+
+// defaultDevices are the devices a container is allowed to access, in the
+// format of devices.allow
+var defaultDevices = []string{
+	// /dev/null and zero
+	"c 1:3 rwm",
+	"c 1:5 rwm",
+
+	// consoles
+	"c 5:1 rwm",
+	"c 5:0 rwm",
+	"c 4:0 rwm",
+	"c 4:1 rwm",
+
+	// /dev/urandom,/dev/random
+	"c 1:9 rwm",
+	"c 1:8 rwm",
+
+	// /dev/pts/* - pts namespaces are "coming soon"
+	"c 136:* rwm",
+	"c 5:2 rwm",
+
+	// tuntap
+	"c 10:200 rwm",
+}
+
+// cgroupSettings returns the settings of the cgroups of the container, in
+// the order they must be applied
+func cgroupSettings(container *Container) []cgroupSetting {
+	var settings []cgroupSetting
+	if container.hostConfig != nil && container.hostConfig.Privileged {
+		// Privileged containers can access every device
+		settings = append(settings, cgroupSetting{"devices", "devices.allow", "a"})
+	} else {
+		// No implicit access to devices
+		settings = append(settings, cgroupSetting{"devices", "devices.deny", "a"})
+		for _, device := range defaultDevices {
+			settings = append(settings, cgroupSetting{"devices", "devices.allow", device})
+		}
+		// Devices given with -device
+		for _, device := range container.devices {
+			settings = append(settings, cgroupSetting{"devices", "devices.allow", device.cgroupRule()})
+		}
+	}
+
+	config := container.Config
+	if config.Memory > 0 {
+		settings = append(settings, cgroupSetting{"memory", "memory.limit_in_bytes", strconv.FormatInt(config.Memory, 10)})
+	}
+	// The soft limit defaults to the hard one
+	if config.MemoryReservation > 0 {
+		settings = append(settings, cgroupSetting{"memory", "memory.soft_limit_in_bytes", strconv.FormatInt(config.MemoryReservation, 10)})
+	} else if config.Memory > 0 {
+		settings = append(settings, cgroupSetting{"memory", "memory.soft_limit_in_bytes", strconv.FormatInt(config.Memory, 10)})
+	}
+	if config.Memory > 0 {
+		if memSwap := getMemorySwap(config); memSwap > 0 {
+			settings = append(settings, cgroupSetting{"memory", "memory.memsw.limit_in_bytes", strconv.FormatInt(memSwap, 10)})
+		}
+	}
+	if config.CpuShares > 0 {
+		settings = append(settings, cgroupSetting{"cpu", "cpu.shares", strconv.FormatInt(config.CpuShares, 10)})
+	}
+	if config.CpuPeriod > 0 {
+		settings = append(settings, cgroupSetting{"cpu", "cpu.cfs_period_us", strconv.FormatInt(config.CpuPeriod, 10)})
+	}
+	if config.CpuQuota > 0 {
+		settings = append(settings, cgroupSetting{"cpu", "cpu.cfs_quota_us", strconv.FormatInt(config.CpuQuota, 10)})
+	}
+	if config.CpusetCpus != "" {
+		settings = append(settings, cgroupSetting{"cpuset", "cpuset.cpus", config.CpusetCpus})
+	}
+	if config.CpusetMems != "" {
+		settings = append(settings, cgroupSetting{"cpuset", "cpuset.mems", config.CpusetMems})
+	}
+	if config.BlkioWeight > 0 {
+		settings = append(settings, cgroupSetting{"blkio", "blkio.weight", strconv.FormatInt(config.BlkioWeight, 10)})
+	}
+	for _, throttle := range []struct {
+		key   string
+		specs []string
+	}{
+		{"blkio.throttle.read_bps_device", config.BlkioDeviceReadBps},
+		{"blkio.throttle.write_bps_device", config.BlkioDeviceWriteBps},
+	} {
+		for _, spec := range throttle.specs {
+			device, rate, err := parseThrottleDevice(spec)
+			if err != nil {
+				utils.Debugf("Skipping %s: %s", spec, err)
+				continue
+			}
+			settings = append(settings, cgroupSetting{"blkio", throttle.key, fmt.Sprintf("%s %d", device, rate)})
+		}
+	}
+	if config.PidsLimit > 0 {
+		settings = append(settings, cgroupSetting{"pids", "pids.max", strconv.FormatInt(config.PidsLimit, 10)})
+	}
+	return settings
+}
+
+// updateSettings returns the settings to write to the cgroups of a running
+// container for an update of its config from old to config. swapLimit tells
+// whether the kernel supports the memory+swap limit.
+func updateSettings(old, config *Config, update *UpdateConfig, swapLimit bool) []cgroupSetting {
+	var settings []cgroupSetting
+	if update.Memory != 0 || update.MemorySwap != 0 {
+		memory := cgroupSetting{"memory", "memory.limit_in_bytes", strconv.FormatInt(config.Memory, 10)}
+		memSwap := cgroupSetting{"memory", "memory.memsw.limit_in_bytes", "-1"}
+		if swap := getMemorySwap(config); swap > 0 {
+			memSwap.Value = strconv.FormatInt(swap, 10)
+		}
+		// The memory limit can never exceed the memory+swap limit
+		if !swapLimit {
+			settings = append(settings, memory)
+		} else if config.Memory > old.Memory {
+			settings = append(settings, memSwap, memory)
+		} else {
+			settings = append(settings, memory, memSwap)
+		}
+		if config.MemoryReservation == 0 {
+			settings = append(settings, cgroupSetting{"memory", "memory.soft_limit_in_bytes", memory.Value})
+		}
+	}
+	if update.CpuShares != 0 {
+		settings = append(settings, cgroupSetting{"cpu", "cpu.shares", strconv.FormatInt(config.CpuShares, 10)})
+	}
+	if update.CpusetCpus != "" {
+		settings = append(settings, cgroupSetting{"cpuset", "cpuset.cpus", config.CpusetCpus})
+	}
+	if update.CpusetMems != "" {
+		settings = append(settings, cgroupSetting{"cpuset", "cpuset.mems", config.CpusetMems})
+	}
+	if update.BlkioWeight != 0 {
+		settings = append(settings, cgroupSetting{"blkio", "blkio.weight", strconv.FormatInt(config.BlkioWeight, 10)})
+	}
+	return settings
+}
+
+// parseThrottleDevice parses a rate limit of the form device:rate and returns
+// the numbers of the device, as major:minor, and the rate
+func parseThrottleDevice(spec string) (string, int64, error) {
+	i := strings.LastIndex(spec, ":")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("Invalid rate limit: %s. It needs to be of the form device:rate.", spec)
+	}
+	rate, err := strconv.ParseInt(spec[i+1:], 10, 64)
+	if err != nil || rate <= 0 {
+		return "", 0, fmt.Errorf("Invalid rate: %s", spec[i+1:])
+	}
+	device := &deviceMapping{PathOnHost: spec[:i]}
+	if err := device.lookup(); err != nil {
+		return "", 0, err
+	}
+	// Only the major:minor part of the device rule is needed
+	return strings.Fields(device.cgroupRule())[1], rate, nil
+}
+
+// applyCgroups creates the cgroups of the container under <mountpoint>/<parent>/<id>
+// for each of the given subsystems, applies the settings and moves pid into them.
+func applyCgroups(parent, id string, pid int, subsystems []string, settings []cgroupSetting) error {
+	if utils.IsCgroupUnified() {
+		return applyUnifiedCgroup(parent, id, pid, subsystems, settings)
+	}
+	for _, subsystem := range subsystems {
+		dir, err := cgroupDir(parent, id, subsystem)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		// Tasks can only join cpusets with CPUs and memory nodes
+		if subsystem == "cpuset" {
+			if err := inheritCpuset(path.Dir(dir)); err != nil {
+				return err
+			}
+			if err := inheritCpuset(dir); err != nil {
+				return err
+			}
+		}
+		for _, setting := range settings {
+			if setting.Subsystem != subsystem {
+				continue
+			}
+			if err := ioutil.WriteFile(path.Join(dir, setting.Key), []byte(setting.Value), 0644); err != nil {
+				return fmt.Errorf("Unable to set %s to %s: %s", setting.Key, setting.Value, err)
+			}
+		}
+		if err := ioutil.WriteFile(path.Join(dir, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inheritCpuset gives the cpuset at dir the CPUs and memory nodes of its
+// parent, unless it has some already
+func inheritCpuset(dir string) error {
+	for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+		value, err := ioutil.ReadFile(path.Join(dir, file))
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(value)) != "" {
+			continue
+		}
+		if value, err = ioutil.ReadFile(path.Join(path.Dir(dir), file)); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path.Join(dir, file), value, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memoryCgroupOOMKilled returns true if the OOM killer killed a process of
+// the memory cgroup at dir. The kernel counts the kills in memory.oom_control
+// (since linux 4.13) or in memory.events on cgroup v2. It fails if neither
+// has the counter.
+func memoryCgroupOOMKilled(dir string) (bool, error) {
+	for _, file := range []string{"memory.oom_control", "memory.events"} {
+		data, err := ioutil.ReadFile(path.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "oom_kill" {
+				return fields[1] != "0", nil
+			}
+		}
+	}
+	return false, fmt.Errorf("No OOM kill counter in %s", dir)
+}
+
+// removeCgroups removes the cgroups created by applyCgroups. They must be empty.
+func removeCgroups(parent, id string, subsystems []string) error {
+	for _, subsystem := range subsystems {
+		dir, err := cgroupDir(parent, id, subsystem)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// cgroupPids returns the pids of the processes in the cgroup of the given subsystem
+func cgroupPids(parent, id, subsystem string) ([]int, error) {
+	dir, err := cgroupDir(parent, id, subsystem)
+	if err != nil {
+		return nil, err
+	}
+	tasks := "tasks"
+	if utils.IsCgroupUnified() {
+		tasks = "cgroup.procs"
+	}
+	data, err := ioutil.ReadFile(path.Join(dir, tasks))
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, line := range strings.Split(string(data), "\n") {
+		if pid, err := strconv.Atoi(strings.TrimSpace(line)); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func cgroupDir(parent, id, subsystem string) (string, error) {
+	mountpoint, err := utils.FindCgroupMountpoint(subsystem)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(mountpoint, parent, id), nil
+}
@@ -0,0 +1,150 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCgroupSettings(t *testing.T) {
+	container := &Container{Config: &Config{}}
+	settings := cgroupSettings(container)
+	if len(settings) != len(defaultDevices)+1 {
+		t.Fatalf("Expected only the devices whitelist, got %v", settings)
+	}
+	// Everything is denied before the whitelist is applied
+	if settings[0] != (cgroupSetting{"devices", "devices.deny", "a"}) {
+		t.Fatalf("The devices must be denied first, got %v", settings[0])
+	}
+
+	container.Config.Memory = 33554432
+	container.Config.CpuShares = 512
+	values := make(map[string]string)
+	for _, setting := range cgroupSettings(container) {
+		values[setting.Key] = setting.Value
+	}
+	expected := map[string]string{
+		"memory.limit_in_bytes":       "33554432",
+		"memory.soft_limit_in_bytes":  "33554432",
+		"memory.memsw.limit_in_bytes": "67108864",
+		"cpu.shares":                  "512",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected %s to be %s, got %s", key, value, values[key])
+		}
+	}
+
+	// Disabling swap limits removes memsw
+	container.Config.MemorySwap = -1
+	for _, setting := range cgroupSettings(container) {
+		if setting.Key == "memory.memsw.limit_in_bytes" {
+			t.Errorf("Unexpected swap limit: %s", setting.Value)
+		}
+	}
+
+	// Extended resource controls
+	container.Config = &Config{
+		Memory:             33554432,
+		MemoryReservation:  16777216,
+		CpuQuota:           50000,
+		CpuPeriod:          100000,
+		CpusetCpus:         "0-1",
+		CpusetMems:         "0",
+		BlkioWeight:        300,
+		BlkioDeviceReadBps: []string{"/dev/null:1048576"},
+		PidsLimit:          100,
+	}
+	values = make(map[string]string)
+	for _, setting := range cgroupSettings(container) {
+		values[setting.Key] = setting.Value
+	}
+	expected = map[string]string{
+		"memory.limit_in_bytes":          "33554432",
+		"memory.soft_limit_in_bytes":     "16777216",
+		"cpu.cfs_quota_us":               "50000",
+		"cpu.cfs_period_us":              "100000",
+		"cpuset.cpus":                    "0-1",
+		"cpuset.mems":                    "0",
+		"blkio.weight":                   "300",
+		"blkio.throttle.read_bps_device": "1:3 1048576",
+		"pids.max":                       "100",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected %s to be %s, got %s", key, value, values[key])
+		}
+	}
+
+	// Privileged containers can access every device
+	container.hostConfig = &HostConfig{Privileged: true}
+	for _, setting := range cgroupSettings(container) {
+		if setting.Key == "devices.deny" {
+			t.Errorf("Unexpected device restriction: %s", setting.Value)
+		}
+	}
+}
+
+func TestUpdateSettings(t *testing.T) {
+	old := &Config{Memory: 33554432}
+	config := &Config{Memory: 67108864}
+	settings := updateSettings(old, config, &UpdateConfig{Memory: 67108864}, true)
+	// Raising the memory limit needs the memory+swap limit to be raised first
+	if len(settings) != 3 || settings[0].Key != "memory.memsw.limit_in_bytes" || settings[0].Value != "134217728" || settings[1].Key != "memory.limit_in_bytes" {
+		t.Fatalf("Unexpected settings: %v", settings)
+	}
+
+	settings = updateSettings(config, old, &UpdateConfig{Memory: 33554432}, true)
+	if settings[0].Key != "memory.limit_in_bytes" || settings[1].Key != "memory.memsw.limit_in_bytes" {
+		t.Fatalf("Unexpected settings: %v", settings)
+	}
+
+	settings = updateSettings(old, &Config{CpuShares: 512}, &UpdateConfig{CpuShares: 512}, false)
+	if len(settings) != 1 || settings[0] != (cgroupSetting{"cpu", "cpu.shares", "512"}) {
+		t.Fatalf("Unexpected settings: %v", settings)
+	}
+}
+
+func TestUpdateMemorySwapWithoutMemory(t *testing.T) {
+	container := &Container{ID: GenerateID(), Config: &Config{}}
+	if err := container.Update(&UpdateConfig{MemorySwap: 67108864}); err == nil || !strings.HasPrefix(err.Error(), "Bad parameter") {
+		t.Fatalf("A memory+swap limit without a memory limit should be refused, got %v", err)
+	}
+}
+
+func TestMemoryCgroupOOMKilled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := memoryCgroupOOMKilled(dir); err == nil {
+		t.Fatalf("Expected a cgroup without a counter to fail")
+	}
+	// Hitting the limit doesn't mean a process was killed
+	files := map[string]string{
+		"memory.failcnt":     "12\n",
+		"memory.oom_control": "oom_kill_disable 0\nunder_oom 0\noom_kill 0\n",
+	}
+	for file, data := range files {
+		if err := ioutil.WriteFile(path.Join(dir, file), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if killed, err := memoryCgroupOOMKilled(dir); err != nil || killed {
+		t.Fatalf("Expected no OOM kill, got %v, %v", killed, err)
+	}
+
+	// cgroup v2
+	os.Remove(path.Join(dir, "memory.oom_control"))
+	events := "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"
+	if err := ioutil.WriteFile(path.Join(dir, "memory.events"), []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if killed, err := memoryCgroupOOMKilled(dir); err != nil || !killed {
+		t.Fatalf("Expected an OOM kill, got %v, %v", killed, err)
+	}
+}
@@ -0,0 +1,194 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// On hosts using the cgroup v2 unified hierarchy, every controller shares
+// the same tree and the files have other names and formats. The settings
+// returned by cgroupSettings use the v1 names and are translated by
+// toUnified. The device whitelist has no file in v2, where it takes an eBPF
+// program: lxc compiles the devices.* settings into one, while the native
+// driver refuses to start containers whose devices it can't restrict.
+
+// unifiedControllers maps the v1 subsystems to the v2 controllers
+var unifiedControllers = map[string]string{
+	"memory": "memory",
+	"cpu":    "cpu",
+	"cpuset": "cpuset",
+	"blkio":  "io",
+	"pids":   "pids",
+}
+
+// toUnified translates cgroup v1 settings to their cgroup v2 counterparts
+func toUnified(settings []cgroupSetting) []cgroupSetting {
+	var memory, memSwap, quota, period int64
+	for _, setting := range settings {
+		value, _ := strconv.ParseInt(setting.Value, 10, 64)
+		switch setting.Key {
+		case "memory.limit_in_bytes":
+			memory = value
+		case "memory.memsw.limit_in_bytes":
+			memSwap = value
+		case "cpu.cfs_quota_us":
+			quota = value
+		case "cpu.cfs_period_us":
+			period = value
+		}
+	}
+
+	var unified []cgroupSetting
+	for _, setting := range settings {
+		switch setting.Key {
+		case "memory.limit_in_bytes":
+			unified = append(unified, cgroupSetting{"memory", "memory.max", setting.Value})
+		case "memory.soft_limit_in_bytes":
+			// The soft limit defaults to the hard one, which memory.low doesn't mean
+			if setting.Value != strconv.FormatInt(memory, 10) {
+				unified = append(unified, cgroupSetting{"memory", "memory.low", setting.Value})
+			}
+		case "memory.memsw.limit_in_bytes":
+			// v1 limits memory+swap, v2 limits swap alone
+			swap := "max"
+			if memSwap > 0 && memory > 0 {
+				swap = strconv.FormatInt(memSwap-memory, 10)
+			}
+			unified = append(unified, cgroupSetting{"memory", "memory.swap.max", swap})
+		case "cpu.shares":
+			shares, _ := strconv.ParseInt(setting.Value, 10, 64)
+			unified = append(unified, cgroupSetting{"cpu", "cpu.weight", strconv.FormatInt(sharesToWeight(shares), 10)})
+		case "cpu.cfs_quota_us", "cpu.cfs_period_us":
+			// Both end up in cpu.max, written once
+			if setting.Key == "cpu.cfs_quota_us" || quota == 0 {
+				max := "max"
+				if quota > 0 {
+					max = strconv.FormatInt(quota, 10)
+				}
+				if period == 0 {
+					period = 100000
+				}
+				unified = append(unified, cgroupSetting{"cpu", "cpu.max", fmt.Sprintf("%s %d", max, period)})
+			}
+		case "cpuset.cpus", "cpuset.mems", "pids.max":
+			unified = append(unified, setting)
+		case "blkio.weight":
+			weight, _ := strconv.ParseInt(setting.Value, 10, 64)
+			unified = append(unified, cgroupSetting{"blkio", "io.weight", strconv.FormatInt(blkioToIOWeight(weight), 10)})
+		case "devices.allow", "devices.deny":
+			// Applied by an eBPF program rather than by a file
+		case "blkio.throttle.read_bps_device", "blkio.throttle.write_bps_device":
+			fields := strings.Fields(setting.Value)
+			if len(fields) != 2 {
+				continue
+			}
+			limit := "rbps"
+			if setting.Key == "blkio.throttle.write_bps_device" {
+				limit = "wbps"
+			}
+			unified = append(unified, cgroupSetting{"blkio", "io.max", fmt.Sprintf("%s %s=%s", fields[0], limit, fields[1])})
+		default:
+			utils.Debugf("%s has no cgroup v2 counterpart, skipping", setting.Key)
+		}
+	}
+	return unified
+}
+
+// sharesToWeight converts cpu.shares, from 2 to 262144, to cpu.weight, from 1 to 10000
+func sharesToWeight(shares int64) int64 {
+	if shares < 2 {
+		shares = 2
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// blkioToIOWeight converts blkio.weight, from 10 to 1000, to io.weight, from 1 to 10000
+func blkioToIOWeight(weight int64) int64 {
+	if weight < 10 {
+		weight = 10
+	}
+	return 1 + ((weight-10)*9999)/990
+}
+
+// applyUnifiedCgroup is applyCgroups for the unified hierarchy, where the
+// cgroup of the container is created once and its controllers are enabled
+// by its parents
+func applyUnifiedCgroup(parent, id string, pid int, subsystems []string, settings []cgroupSetting) error {
+	mountpoint, err := utils.FindCgroup2Mountpoint()
+	if err != nil {
+		return err
+	}
+	var controllers []string
+	for _, subsystem := range subsystems {
+		if controller, exists := unifiedControllers[subsystem]; exists {
+			controllers = append(controllers, "+"+controller)
+		}
+	}
+	dir := path.Join(mountpoint, parent, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if len(controllers) > 0 {
+		for _, p := range []string{mountpoint, path.Join(mountpoint, parent)} {
+			if err := ioutil.WriteFile(path.Join(p, "cgroup.subtree_control"), []byte(strings.Join(controllers, " ")), 0644); err != nil {
+				return fmt.Errorf("Unable to enable the controllers %v: %s", controllers, err)
+			}
+		}
+	}
+	for _, setting := range toUnified(settings) {
+		if err := ioutil.WriteFile(path.Join(dir, setting.Key), []byte(setting.Value), 0644); err != nil {
+			return fmt.Errorf("Unable to set %s to %s: %s", setting.Key, setting.Value, err)
+		}
+	}
+	return ioutil.WriteFile(path.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644)
+}
+
+// unifiedCapabilities detects the controls supported by the unified hierarchy
+func unifiedCapabilities() *Capabilities {
+	capabilities := &Capabilities{}
+	mountpoint, err := utils.FindCgroup2Mountpoint()
+	if err != nil {
+		return capabilities
+	}
+	data, err := ioutil.ReadFile(path.Join(mountpoint, "cgroup.controllers"))
+	if err != nil {
+		return capabilities
+	}
+	controllers := make(map[string]bool)
+	for _, controller := range strings.Fields(string(data)) {
+		controllers[controller] = true
+	}
+	capabilities.MemoryLimit = controllers["memory"]
+	// Swap accounting shows in the non-root cgroups only, eg. the daemon's
+	if capabilities.MemoryLimit {
+		if own, err := ownUnifiedCgroup(); err == nil {
+			_, err := os.Stat(path.Join(mountpoint, own, "memory.swap.max"))
+			capabilities.SwapLimit = err == nil
+		}
+	}
+	capabilities.CpuCfsQuota = controllers["cpu"]
+	capabilities.Cpuset = controllers["cpuset"]
+	capabilities.BlkioWeight = controllers["io"]
+	capabilities.BlkioThrottle = controllers["io"]
+	capabilities.PidsLimit = controllers["pids"]
+	return capabilities
+}
+
+// ownUnifiedCgroup returns the cgroup v2 path of the current process
+func ownUnifiedCgroup() (string, error) {
+	data, err := ioutil.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	return "", fmt.Errorf("No cgroup v2 found for the current process")
+}
@@ -0,0 +1,49 @@
+package docker
+
+import (
+	"testing"
+)
+
+func TestToUnified(t *testing.T) {
+	settings := []cgroupSetting{
+		{"devices", "devices.deny", "a"},
+		{"memory", "memory.limit_in_bytes", "33554432"},
+		{"memory", "memory.soft_limit_in_bytes", "33554432"},
+		{"memory", "memory.memsw.limit_in_bytes", "67108864"},
+		{"cpu", "cpu.shares", "1024"},
+		{"cpu", "cpu.cfs_period_us", "50000"},
+		{"cpu", "cpu.cfs_quota_us", "25000"},
+		{"cpuset", "cpuset.cpus", "0-1"},
+		{"blkio", "blkio.weight", "500"},
+		{"blkio", "blkio.throttle.write_bps_device", "8:0 1048576"},
+		{"pids", "pids.max", "100"},
+	}
+	expected := []cgroupSetting{
+		{"memory", "memory.max", "33554432"},
+		{"memory", "memory.swap.max", "33554432"},
+		{"cpu", "cpu.weight", "39"},
+		{"cpu", "cpu.max", "25000 50000"},
+		{"cpuset", "cpuset.cpus", "0-1"},
+		{"blkio", "io.weight", "4950"},
+		{"blkio", "io.max", "8:0 wbps=1048576"},
+		{"pids", "pids.max", "100"},
+	}
+	unified := toUnified(settings)
+	if len(unified) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, unified)
+	}
+	for i := range expected {
+		if unified[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], unified[i])
+		}
+	}
+
+	// A reservation below the limit maps to memory.low
+	unified = toUnified([]cgroupSetting{
+		{"memory", "memory.limit_in_bytes", "33554432"},
+		{"memory", "memory.soft_limit_in_bytes", "16777216"},
+	})
+	if len(unified) != 2 || unified[1] != (cgroupSetting{"memory", "memory.low", "16777216"}) {
+		t.Fatalf("Unexpected settings: %v", unified)
+	}
+}
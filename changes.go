@@ -15,6 +15,28 @@ const (
 	ChangeDelete
 )
 
+// excludeMountpoints removes the mountpoints created for a container from
+// its changes, as well as the modifications of their parent directories, whose
+// times changed when they were created
+func excludeMountpoints(changes []Change, mountpoints []string) []Change {
+	filtered := []Change{}
+	for _, change := range changes {
+		excluded := false
+		for _, mountpoint := range mountpoints {
+			switch {
+			case change.Kind != ChangeDelete && change.Path == mountpoint:
+				excluded = true
+			case change.Kind == ChangeModify && strings.HasPrefix(mountpoint, change.Path+"/"):
+				excluded = true
+			}
+		}
+		if !excluded {
+			filtered = append(filtered, change)
+		}
+	}
+	return filtered
+}
+
 type Change struct {
 	Path string
 	Kind ChangeType
@@ -57,6 +79,14 @@ func Changes(layers []string, rw string) ([]Change, error) {
 			return err
 		}
 
+		// Skip the mount point of the docker-init control directory
+		if path == controlDir {
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		change := Change{
 			Path: path,
 		}
@@ -77,6 +77,7 @@ func (cli *DockerCli) CmdHelp(args ...string) error {
 		{"attach", "Attach to a running container"},
 		{"build", "Build a container from a Dockerfile"},
 		{"commit", "Create a new image from a container's changes"},
+		{"cp", "Copy files/folders between a container and the host"},
 		{"diff", "Inspect changes on a container's filesystem"},
 		{"export", "Stream the contents of a container as a tar archive"},
 		{"history", "Show the history of an image"},
@@ -101,7 +102,9 @@ func (cli *DockerCli) CmdHelp(args ...string) error {
 		{"start", "Start a stopped container"},
 		{"stop", "Stop a running container"},
 		{"tag", "Tag an image into a repository"},
+		{"update", "Update the resource limits of a container"},
 		{"version", "Show the docker version information"},
+		{"volume", "Manage volumes"},
 		{"wait", "Block until a container stops, then print its exit code"},
 	} {
 		help += fmt.Sprintf("    %-10.10s%s\n", command[0], command[1])
@@ -452,6 +455,9 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 
 	fmt.Fprintf(cli.out, "Containers: %d\n", out.Containers)
 	fmt.Fprintf(cli.out, "Images: %d\n", out.Images)
+	if out.ExecutionDriver != "" {
+		fmt.Fprintf(cli.out, "Execution Driver: %s\n", out.ExecutionDriver)
+	}
 	if out.Debug || os.Getenv("DEBUG") != "" {
 		fmt.Fprintf(cli.out, "Debug mode (server): %v\n", out.Debug)
 		fmt.Fprintf(cli.out, "Debug mode (client): %v\n", os.Getenv("DEBUG") != "")
@@ -464,6 +470,21 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 	if !out.SwapLimit {
 		fmt.Fprintf(cli.err, "WARNING: No swap limit support\n")
 	}
+	if !out.CpuCfsQuota {
+		fmt.Fprintf(cli.err, "WARNING: No cpu quota support\n")
+	}
+	if !out.Cpuset {
+		fmt.Fprintf(cli.err, "WARNING: No cpuset support\n")
+	}
+	if !out.BlkioWeight {
+		fmt.Fprintf(cli.err, "WARNING: No blkio weight support\n")
+	}
+	if !out.BlkioThrottle {
+		fmt.Fprintf(cli.err, "WARNING: No blkio throttle support\n")
+	}
+	if !out.PidsLimit {
+		fmt.Fprintf(cli.err, "WARNING: No pids limit support\n")
+	}
 	return nil
 }
 
@@ -517,6 +538,214 @@ func (cli *DockerCli) CmdRestart(args ...string) error {
 	return nil
 }
 
+func (cli *DockerCli) CmdUpdate(args ...string) error {
+	cmd := Subcmd("update", "[OPTIONS] CONTAINER [CONTAINER...]", "Update the resource limits of a container")
+	flMemory := cmd.Int64("m", 0, "Memory limit (in bytes)")
+	flMemorySwap := cmd.Int64("memory-swap", 0, "Set -1 to disable the swap limit")
+	flCpuShares := cmd.Int64("c", 0, "CPU shares (relative weight)")
+	flCpusetCpus := cmd.String("cpuset-cpus", "", "CPUs in which to allow execution (e.g. 0-3, 0,1)")
+	flCpusetMems := cmd.String("cpuset-mems", "", "Memory nodes in which to allow allocation (e.g. 0-3, 0,1)")
+	flBlkioWeight := cmd.Int64("blkio-weight", 0, "Block IO weight (relative weight between 10 and 1000)")
+	if err := cmd.Parse(args); err != nil {
+		return nil
+	}
+	if cmd.NArg() < 1 {
+		cmd.Usage()
+		return nil
+	}
+
+	update := &UpdateConfig{
+		Memory:      *flMemory,
+		MemorySwap:  *flMemorySwap,
+		CpuShares:   *flCpuShares,
+		CpusetCpus:  *flCpusetCpus,
+		CpusetMems:  *flCpusetMems,
+		BlkioWeight: *flBlkioWeight,
+	}
+	for _, name := range cmd.Args() {
+		_, _, err := cli.call("POST", "/containers/"+name+"/update", update)
+		if err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+		} else {
+			fmt.Fprintf(cli.out, "%s\n", name)
+		}
+	}
+	return nil
+}
+
+func (cli *DockerCli) CmdVolume(args ...string) error {
+	cmd := Subcmd("volume", "ls|create|inspect|rm|prune [ARGS]", "Manage volumes")
+	if err := cmd.Parse(args); err != nil {
+		return nil
+	}
+	if cmd.NArg() < 1 {
+		cmd.Usage()
+		return nil
+	}
+	switch cmd.Arg(0) {
+	case "ls":
+		return cli.volumeLs(cmd.Args()[1:]...)
+	case "create":
+		return cli.volumeCreate(cmd.Args()[1:]...)
+	case "inspect":
+		return cli.volumeInspect(cmd.Args()[1:]...)
+	case "rm":
+		return cli.volumeRm(cmd.Args()[1:]...)
+	case "prune":
+		return cli.volumePrune(cmd.Args()[1:]...)
+	}
+	cmd.Usage()
+	return nil
+}
+
+func (cli *DockerCli) volumeLs(args ...string) error {
+	cmd := Subcmd("volume ls", "[OPTIONS]", "List volumes")
+	quiet := cmd.Bool("q", false, "Only display names")
+	if err := cmd.Parse(args); err != nil {
+		return nil
+	}
+	if cmd.NArg() != 0 {
+		cmd.Usage()
+		return nil
+	}
+
+	body, _, err := cli.call("GET", "/volumes", nil)
+	if err != nil {
+		return err
+	}
+	var outs []APIVolume
+	if err := json.Unmarshal(body, &outs); err != nil {
+		return err
+	}
+	if *quiet {
+		for _, out := range outs {
+			fmt.Fprintln(cli.out, out.Name)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDRIVER\tCREATED\tCONTAINERS")
+	for _, out := range outs {
+		for i := range out.UsedBy {
+			out.UsedBy[i] = utils.TruncateID(out.UsedBy[i])
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s ago\t%s\n", out.Name, out.Driver, utils.HumanDuration(time.Now().Sub(time.Unix(out.Created, 0))), strings.Join(out.UsedBy, ","))
+	}
+	w.Flush()
+	return nil
+}
+
+func (cli *DockerCli) volumeCreate(args ...string) error {
+	cmd := Subcmd("volume create", "[OPTIONS] NAME", "Create a volume")
+	flDriver := cmd.String("driver", "local", "Driver of the volume")
+	var flOpts ListOpts
+	cmd.Var(&flOpts, "o", "Set an option of the driver (e.g. -o server=10.0.0.2)")
+	if err := cmd.Parse(args); err != nil {
+		return nil
+	}
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		return nil
+	}
+	config := &APIVolumeCreate{Name: cmd.Arg(0), Driver: *flDriver}
+	for _, opt := range flOpts {
+		parts := strings.SplitN(opt, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid driver option: %s. It needs to be of the form key=value.", opt)
+		}
+		if config.DriverOpts == nil {
+			config.DriverOpts = make(map[string]string)
+		}
+		config.DriverOpts[parts[0]] = parts[1]
+	}
+	body, _, err := cli.call("POST", "/volumes/create", config)
+	if err != nil {
+		return err
+	}
+	var out APIVolume
+	if err := json.Unmarshal(body, &out); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.out, out.Name)
+	return nil
+}
+
+func (cli *DockerCli) volumeInspect(args ...string) error {
+	cmd := Subcmd("volume inspect", "VOLUME [VOLUME...]", "Return low-level information on a volume")
+	if err := cmd.Parse(args); err != nil {
+		return nil
+	}
+	if cmd.NArg() < 1 {
+		cmd.Usage()
+		return nil
+	}
+	fmt.Fprintf(cli.out, "[")
+	for i, name := range cmd.Args() {
+		if i > 0 {
+			fmt.Fprintf(cli.out, ",")
+		}
+		obj, _, err := cli.call("GET", "/volumes/"+name, nil)
+		if err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			continue
+		}
+		indented := new(bytes.Buffer)
+		if err = json.Indent(indented, obj, "", "    "); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			continue
+		}
+		if _, err := io.Copy(cli.out, indented); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+		}
+	}
+	fmt.Fprintf(cli.out, "]")
+	return nil
+}
+
+func (cli *DockerCli) volumeRm(args ...string) error {
+	cmd := Subcmd("volume rm", "VOLUME [VOLUME...]", "Remove one or more volumes")
+	if err := cmd.Parse(args); err != nil {
+		return nil
+	}
+	if cmd.NArg() < 1 {
+		cmd.Usage()
+		return nil
+	}
+	for _, name := range cmd.Args() {
+		_, _, err := cli.call("DELETE", "/volumes/"+name, nil)
+		if err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+		} else {
+			fmt.Fprintf(cli.out, "%s\n", name)
+		}
+	}
+	return nil
+}
+
+func (cli *DockerCli) volumePrune(args ...string) error {
+	cmd := Subcmd("volume prune", "", "Remove the volumes which no container uses")
+	if err := cmd.Parse(args); err != nil {
+		return nil
+	}
+	if cmd.NArg() != 0 {
+		cmd.Usage()
+		return nil
+	}
+	body, _, err := cli.call("POST", "/volumes/prune", nil)
+	if err != nil {
+		return err
+	}
+	var out APIVolumesPrune
+	if err := json.Unmarshal(body, &out); err != nil {
+		return err
+	}
+	for _, name := range out.VolumesDeleted {
+		fmt.Fprintf(cli.out, "Deleted: %s\n", name)
+	}
+	fmt.Fprintf(cli.out, "Total reclaimed space: %s\n", utils.HumanSize(out.SpaceReclaimed))
+	return nil
+}
+
 func (cli *DockerCli) CmdStart(args ...string) error {
 	cmd := Subcmd("start", "CONTAINER [CONTAINER...]", "Restart a stopped container")
 	if err := cmd.Parse(args); err != nil {
@@ -1063,6 +1292,64 @@ func (cli *DockerCli) CmdExport(args ...string) error {
 	return nil
 }
 
+// 'docker cp': copy files or folders out of or into a container
+func (cli *DockerCli) CmdCp(args ...string) error {
+	cmd := Subcmd("cp", "CONTAINER:PATH HOSTPATH|- | HOSTPATH|- CONTAINER:PATH", "Copy files/folders between a container and the host.\nUse '-' to stream a tar archive from stdin or to stdout.")
+	if err := cmd.Parse(args); err != nil {
+		return nil
+	}
+	if cmd.NArg() != 2 {
+		cmd.Usage()
+		return nil
+	}
+
+	if src := strings.SplitN(cmd.Arg(0), ":", 2); len(src) == 2 {
+		// Copy out of the container
+		v := url.Values{}
+		v.Set("path", src[1])
+		if cmd.Arg(1) == "-" {
+			return cli.stream("GET", "/containers/"+src[0]+"/archive?"+v.Encode(), nil, cli.out)
+		}
+		if err := os.MkdirAll(cmd.Arg(1), 0755); err != nil {
+			return err
+		}
+		r, w := io.Pipe()
+		untarErr := utils.Go(func() error {
+			err := Untar(r, cmd.Arg(1))
+			// Unblock the stream if Untar stops reading early
+			r.CloseWithError(err)
+			return err
+		})
+		err := cli.stream("GET", "/containers/"+src[0]+"/archive?"+v.Encode(), nil, w)
+		w.CloseWithError(err)
+		if err := <-untarErr; err != nil {
+			return err
+		}
+		return err
+	}
+
+	if dst := strings.SplitN(cmd.Arg(1), ":", 2); len(dst) == 2 {
+		// Copy into the container
+		var context io.Reader
+		if cmd.Arg(0) == "-" {
+			context = cli.in
+		} else {
+			src := filepath.Clean(cmd.Arg(0))
+			archive, err := TarFilter(filepath.Dir(src), Uncompressed, []string{filepath.Base(src)})
+			if err != nil {
+				return err
+			}
+			context = archive
+		}
+		v := url.Values{}
+		v.Set("path", dst[1])
+		return cli.stream("PUT", "/containers/"+dst[0]+"/archive?"+v.Encode(), context, cli.out)
+	}
+
+	cmd.Usage()
+	return nil
+}
+
 func (cli *DockerCli) CmdDiff(args ...string) error {
 	cmd := Subcmd("diff", "CONTAINER", "Inspect changes on a container's filesystem")
 	if err := cmd.Parse(args); err != nil {
@@ -1249,6 +1536,14 @@ func (opts PathOpts) String() string {
 }
 
 func (opts PathOpts) Set(val string) error {
+	// Named volumes are given as binds, name:path[:options]
+	if strings.Contains(val, ":") {
+		if _, err := parseBind(val); err != nil {
+			return err
+		}
+		opts[val] = struct{}{}
+		return nil
+	}
 	if !filepath.IsAbs(val) {
 		return fmt.Errorf("%s is not an absolute path", val)
 	}
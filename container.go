@@ -40,6 +40,21 @@ type Container struct {
 
 	SysInitPath    string
 	ResolvConfPath string
+	HostsPath      string
+
+	// Linux capabilities kept by the running container
+	Capabilities []string
+
+	// Security profiles of the running container. An empty AppArmorProfile
+	// is the default profile of the execution driver. SeccompProfile is
+	// default, custom or unconfined.
+	AppArmorProfile string
+	SeccompProfile  string
+
+	// SELinux label of the processes of the running container, which gets
+	// MCS categories of its own when it relabels binds as private. Empty
+	// keeps the label of the execution driver.
+	ProcessLabel string
 
 	cmd       *exec.Cmd
 	stdout    *utils.WriteBroadcaster
@@ -50,19 +65,41 @@ type Container struct {
 
 	runtime *Runtime
 
-	waitLock chan struct{}
-	Volumes  map[string]string
+	waitLock   chan struct{}
+	oomEvents  chan struct{}
+	hostConfig *HostConfig
+	devices    []*deviceMapping
+	seccomp    *seccompProfile
+	Volumes    map[string]string
 	// Store rw/ro in a separate structure to preserve reserve-compatibility on-disk.
 	// Easier than migrating older container configs :)
 	VolumesRW map[string]bool
+	// Mount propagation of the binds, for those which set it
+	VolumesPropagation map[string]string
+	// Names of the volumes of the volume store, by path in the container
+	VolumeNames map[string]string
+	// Files created by Start in the root filesystem to mount things on, which
+	// aren't changes of the container
+	Mountpoints []string
 }
 
 type Config struct {
-	Hostname     string
-	User         string
-	Memory       int64 // Memory limit (in bytes)
-	MemorySwap   int64 // Total memory usage (memory + swap); set `-1' to disable swap
-	CpuShares    int64 // CPU shares (relative weight vs. other containers)
+	Hostname   string
+	User       string
+	Memory     int64 // Memory limit (in bytes)
+	MemorySwap int64 // Total memory usage (memory + swap); set `-1' to disable swap
+	CpuShares  int64 // CPU shares (relative weight vs. other containers)
+
+	MemoryReservation   int64    // Memory soft limit (in bytes)
+	CpusetCpus          string   // CPUs the container can run on, e.g. 0-3 or 0,1
+	CpusetMems          string   // Memory nodes the container can allocate from
+	CpuQuota            int64    // CPU time the container can use per CpuPeriod (in microseconds)
+	CpuPeriod           int64    // Length of the CFS period (in microseconds)
+	BlkioWeight         int64    // Block IO weight (relative weight, between 10 and 1000)
+	BlkioDeviceReadBps  []string // Read rate limits, as device:bytes-per-second
+	BlkioDeviceWriteBps []string // Write rate limits, as device:bytes-per-second
+	PidsLimit           int64    // Maximum number of processes
+
 	AttachStdin  bool
 	AttachStdout bool
 	AttachStderr bool
@@ -73,20 +110,60 @@ type Config struct {
 	Env          []string
 	Cmd          []string
 	Dns          []string
-	Image        string // Name of the image as it was passed by the operator (eg. could be symbolic)
+	DnsSearch    []string // Search domains of resolv.conf
+	DnsOptions   []string // Options of resolv.conf, e.g. ndots:2
+	Image        string   // Name of the image as it was passed by the operator (eg. could be symbolic)
 	Volumes      map[string]struct{}
 	VolumesFrom  string
 	Entrypoint   []string
+	WorkingDir   string // Directory in which the command is run, created if necessary
 }
 
 type HostConfig struct {
-	Binds []string
+	Binds      []string
+	Init       bool     // Run docker-init as PID 1 to reap zombies and forward signals
+	CapAdd     []string // Capabilities to keep on top of the default set, or "all"
+	CapDrop    []string // Capabilities to remove from the default set, or "all"
+	Privileged bool     // Keep every capability and give access to every device
+	Devices    []string // Host devices given to the container, as host:container:rwm
+
+	ReadonlyRootfs bool // Mount the root filesystem read-only, volumes and binds keep their mode
+
+	Tmpfs   map[string]string // tmpfs filesystems to mount, path -> mount options
+	ShmSize int64             // Size of /dev/shm (in bytes)
+
+	ExtraHosts []string // Entries added to /etc/hosts, as host:ip
+
+	Ulimits []*Ulimit // Resource limits, on top of DefaultUlimits
+
+	SecurityOpt []string // AppArmor and seccomp profiles, as apparmor=PROFILE or seccomp=PROFILE
+
+	VolumeDriver string // VolumeDriver of the volumes created for the container, local by default
 }
 
-type BindMap struct {
-	SrcPath string
-	DstPath string
-	Mode    string
+// UpdateConfig holds the resource limits which can be changed while a
+// container is running. Zero values are left unchanged.
+type UpdateConfig struct {
+	Memory      int64 // Memory limit (in bytes)
+	MemorySwap  int64 // Set `-1' to disable swap
+	CpuShares   int64
+	CpusetCpus  string
+	CpusetMems  string
+	BlkioWeight int64
+}
+
+// parseVolumesFrom parses a container given to -volumes-from, of the form
+// name[:ro|rw]. Without a mode, the volumes keep the one they have in the
+// container.
+func parseVolumesFrom(spec string) (string, string, error) {
+	parts := strings.Split(spec, ":")
+	if parts[0] == "" || len(parts) > 2 || (len(parts) == 2 && parts[1] != "ro" && parts[1] != "rw") {
+		return "", "", fmt.Errorf("Invalid volumes-from specification: %s. It needs to be of the form container[:ro|rw].", spec)
+	}
+	if len(parts) == 1 {
+		return parts[0], "", nil
+	}
+	return parts[0], parts[1], nil
 }
 
 func ParseRun(args []string, capabilities *Capabilities) (*Config, *HostConfig, *flag.FlagSet, error) {
@@ -110,6 +187,19 @@ func ParseRun(args []string, capabilities *Capabilities) (*Config, *HostConfig,
 	}
 
 	flCpuShares := cmd.Int64("c", 0, "CPU shares (relative weight)")
+	flMemoryReservation := cmd.Int64("memory-reservation", 0, "Memory soft limit (in bytes)")
+	flCpusetCpus := cmd.String("cpuset-cpus", "", "CPUs in which to allow execution (e.g. 0-3, 0,1)")
+	flCpusetMems := cmd.String("cpuset-mems", "", "Memory nodes in which to allow allocation (e.g. 0-3, 0,1)")
+	flCpuQuota := cmd.Int64("cpu-quota", 0, "Limit the CPU time per period (in microseconds)")
+	flCpuPeriod := cmd.Int64("cpu-period", 0, "Length of the CPU period (in microseconds)")
+	flBlkioWeight := cmd.Int64("blkio-weight", 0, "Block IO weight (relative weight between 10 and 1000)")
+	flPidsLimit := cmd.Int64("pids-limit", 0, "Limit the number of processes")
+
+	var flDeviceReadBps ListOpts
+	cmd.Var(&flDeviceReadBps, "device-read-bps", "Limit the read rate from a device (e.g. -device-read-bps /dev/sda:1048576)")
+
+	var flDeviceWriteBps ListOpts
+	cmd.Var(&flDeviceWriteBps, "device-write-bps", "Limit the write rate to a device (e.g. -device-write-bps /dev/sda:1048576)")
 
 	var flPorts ListOpts
 	cmd.Var(&flPorts, "p", "Expose a container's port to the host (use 'docker port' to see the actual mapping)")
@@ -120,21 +210,134 @@ func ParseRun(args []string, capabilities *Capabilities) (*Config, *HostConfig,
 	var flDns ListOpts
 	cmd.Var(&flDns, "dns", "Set custom dns servers")
 
+	var flDnsSearch ListOpts
+	cmd.Var(&flDnsSearch, "dns-search", "Set custom dns search domains")
+
+	var flDnsOptions ListOpts
+	cmd.Var(&flDnsOptions, "dns-opt", "Set resolv.conf options (e.g. -dns-opt ndots:2)")
+
+	var flUlimits ListOpts
+	cmd.Var(&flUlimits, "ulimit", "Set a resource limit (e.g. -ulimit nofile=1024:4096)")
+
+	var flExtraHosts ListOpts
+	cmd.Var(&flExtraHosts, "add-host", "Add an entry to /etc/hosts (e.g. -add-host db:10.0.0.2)")
+
 	flVolumes := NewPathOpts()
-	cmd.Var(flVolumes, "v", "Attach a data volume")
+	cmd.Var(flVolumes, "v", "Attach a data volume, or a named volume (e.g. -v data:/var/lib/data)")
 
-	flVolumesFrom := cmd.String("volumes-from", "", "Mount volumes from the specified container")
+	var flVolumesFrom ListOpts
+	cmd.Var(&flVolumesFrom, "volumes-from", "Mount the volumes of a container, optionally read-only or read-write (e.g. -volumes-from db:ro)")
+	flVolumeDriver := cmd.String("volume-driver", "", "Driver of the volumes created for the container")
 	flEntrypoint := cmd.String("entrypoint", "", "Overwrite the default entrypoint of the image")
+	flWorkingDir := cmd.String("w", "", "Working directory inside the container")
+	flInit := cmd.Bool("init", false, "Run an init inside the container that forwards signals and reaps processes")
 
 	var flBinds ListOpts
 	cmd.Var(&flBinds, "b", "Bind mount a volume from the host (e.g. -b /host:/container)")
 
+	var flCapAdd ListOpts
+	cmd.Var(&flCapAdd, "cap-add", "Add a Linux capability, or 'all'")
+
+	var flCapDrop ListOpts
+	cmd.Var(&flCapDrop, "cap-drop", "Drop a Linux capability, or 'all'")
+
+	var flDevices ListOpts
+	cmd.Var(&flDevices, "device", "Add a host device to the container (e.g. -device /dev/fuse:/dev/fuse:rwm)")
+
+	var flTmpfs ListOpts
+	cmd.Var(&flTmpfs, "tmpfs", "Mount a tmpfs filesystem (e.g. -tmpfs /run:size=64m)")
+
+	flShmSize := cmd.Int64("shm-size", 0, "Size of /dev/shm (in bytes)")
+	flReadonly := cmd.Bool("read-only", false, "Mount the container's root filesystem as read only")
+	flPrivileged := cmd.Bool("privileged", false, "Give all capabilities and access to all devices to the container")
+
+	var flSecurityOpt ListOpts
+	cmd.Var(&flSecurityOpt, "security-opt", "Set an AppArmor profile or a seccomp profile file (e.g. -security-opt seccomp=/path/to/profile.json)")
+
 	if err := cmd.Parse(args); err != nil {
 		return nil, nil, cmd, err
 	}
 	if *flDetach && len(flAttach) > 0 {
 		return nil, nil, cmd, fmt.Errorf("Conflicting options: -a and -d")
 	}
+	if *flWorkingDir != "" && !path.IsAbs(*flWorkingDir) {
+		return nil, nil, cmd, fmt.Errorf("The working directory %s is invalid. It needs to be an absolute path.", *flWorkingDir)
+	}
+	if *flCpuPeriod != 0 && (*flCpuPeriod < 1000 || *flCpuPeriod > 1000000) {
+		return nil, nil, cmd, fmt.Errorf("The CPU period must be between 1000 and 1000000 microseconds")
+	}
+	if *flCpuQuota != 0 && *flCpuQuota < 1000 {
+		return nil, nil, cmd, fmt.Errorf("The CPU quota must be at least 1000 microseconds")
+	}
+	if *flBlkioWeight != 0 && (*flBlkioWeight < 10 || *flBlkioWeight > 1000) {
+		return nil, nil, cmd, fmt.Errorf("The block IO weight must be between 10 and 1000")
+	}
+	if *flMemory > 0 && *flMemoryReservation > *flMemory {
+		return nil, nil, cmd, fmt.Errorf("The memory reservation can't be greater than the memory limit")
+	}
+	for _, specs := range [][]string{flDeviceReadBps, flDeviceWriteBps} {
+		for _, spec := range specs {
+			if _, _, err := parseThrottleDevice(spec); err != nil {
+				return nil, nil, cmd, err
+			}
+		}
+	}
+	if err := validateCapabilities(flCapAdd); err != nil {
+		return nil, nil, cmd, err
+	}
+	if err := validateCapabilities(flCapDrop); err != nil {
+		return nil, nil, cmd, err
+	}
+	for _, device := range flDevices {
+		if _, err := parseDevice(device); err != nil {
+			return nil, nil, cmd, err
+		}
+	}
+	for _, host := range flExtraHosts {
+		if _, _, err := parseExtraHost(host); err != nil {
+			return nil, nil, cmd, err
+		}
+	}
+	ulimits := []*Ulimit{}
+	for _, spec := range flUlimits {
+		u, err := ParseUlimit(spec)
+		if err != nil {
+			return nil, nil, cmd, err
+		}
+		ulimits = append(ulimits, u)
+	}
+	for _, spec := range flVolumesFrom {
+		if _, _, err := parseVolumesFrom(spec); err != nil {
+			return nil, nil, cmd, err
+		}
+	}
+	securityOpt := []string{}
+	for _, opt := range flSecurityOpt {
+		key, value, err := parseSecurityOpt(opt)
+		if err != nil {
+			return nil, nil, cmd, err
+		}
+		// Seccomp profiles are read from files on the client side
+		if key == "seccomp" && value != "unconfined" {
+			data, err := ioutil.ReadFile(value)
+			if err != nil {
+				return nil, nil, cmd, fmt.Errorf("Unable to read the seccomp profile %s: %s", value, err)
+			}
+			if _, err := parseSeccompProfile(data); err != nil {
+				return nil, nil, cmd, err
+			}
+			opt = "seccomp=" + string(data)
+		}
+		securityOpt = append(securityOpt, opt)
+	}
+	tmpfs := make(map[string]string)
+	for _, spec := range flTmpfs {
+		target, options, err := parseTmpfs(spec)
+		if err != nil {
+			return nil, nil, cmd, err
+		}
+		tmpfs[target] = options
+	}
 	// If neither -d or -a are set, attach to everything by default
 	if len(flAttach) == 0 && !*flDetach {
 		if !*flDetach {
@@ -146,11 +349,21 @@ func ParseRun(args []string, capabilities *Capabilities) (*Config, *HostConfig,
 		}
 	}
 
+	// Named volumes are mounted like binds
+	for volume := range flVolumes {
+		if strings.Contains(volume, ":") {
+			delete(flVolumes, volume)
+			flBinds = append(flBinds, volume)
+		}
+	}
+
 	// add any bind targets to the list of container volumes
-	for _, bind := range flBinds {
-		arr := strings.Split(bind, ":")
-		dstDir := arr[1]
-		flVolumes[dstDir] = struct{}{}
+	for _, spec := range flBinds {
+		bind, err := parseBind(spec)
+		if err != nil {
+			return nil, nil, cmd, err
+		}
+		flVolumes[bind.DstPath] = struct{}{}
 	}
 
 	parsedArgs := cmd.Args()
@@ -168,26 +381,57 @@ func ParseRun(args []string, capabilities *Capabilities) (*Config, *HostConfig,
 	}
 
 	config := &Config{
-		Hostname:     *flHostname,
-		PortSpecs:    flPorts,
-		User:         *flUser,
-		Tty:          *flTty,
-		OpenStdin:    *flStdin,
-		Memory:       *flMemory,
-		CpuShares:    *flCpuShares,
+		Hostname:  *flHostname,
+		PortSpecs: flPorts,
+		User:      *flUser,
+		Tty:       *flTty,
+		OpenStdin: *flStdin,
+		Memory:    *flMemory,
+		CpuShares: *flCpuShares,
+
+		MemoryReservation:   *flMemoryReservation,
+		CpusetCpus:          *flCpusetCpus,
+		CpusetMems:          *flCpusetMems,
+		CpuQuota:            *flCpuQuota,
+		CpuPeriod:           *flCpuPeriod,
+		BlkioWeight:         *flBlkioWeight,
+		BlkioDeviceReadBps:  flDeviceReadBps,
+		BlkioDeviceWriteBps: flDeviceWriteBps,
+		PidsLimit:           *flPidsLimit,
+
 		AttachStdin:  flAttach.Get("stdin"),
 		AttachStdout: flAttach.Get("stdout"),
 		AttachStderr: flAttach.Get("stderr"),
 		Env:          flEnv,
 		Cmd:          runCmd,
 		Dns:          flDns,
+		DnsSearch:    flDnsSearch,
+		DnsOptions:   flDnsOptions,
 		Image:        image,
 		Volumes:      flVolumes,
-		VolumesFrom:  *flVolumesFrom,
+		VolumesFrom:  strings.Join(flVolumesFrom, ","),
 		Entrypoint:   entrypoint,
+		WorkingDir:   *flWorkingDir,
 	}
 	hostConfig := &HostConfig{
-		Binds: flBinds,
+		Binds:      flBinds,
+		Init:       *flInit,
+		CapAdd:     flCapAdd,
+		CapDrop:    flCapDrop,
+		Privileged: *flPrivileged,
+		Devices:    flDevices,
+
+		ReadonlyRootfs: *flReadonly,
+
+		Tmpfs:   tmpfs,
+		ShmSize: *flShmSize,
+
+		ExtraHosts: flExtraHosts,
+		Ulimits:    ulimits,
+
+		SecurityOpt: securityOpt,
+
+		VolumeDriver: *flVolumeDriver,
 	}
 
 	if capabilities != nil && *flMemory > 0 && !capabilities.SwapLimit {
@@ -225,21 +469,52 @@ func (settings *NetworkSettings) PortMappingHuman() string {
 	return strings.Join(mapping, ", ")
 }
 
+// injectDir returns the path on the host of the directory `dir` inside the
+// container, creating it if necessary
+func (container *Container) injectDir(dir string) (string, error) {
+	if err := container.EnsureMounted(); err != nil {
+		return "", err
+	}
+	dirPath, err := container.hostPath(dir)
+	if err != nil {
+		return "", err
+	}
+	if st, err := os.Stat(dirPath); err == nil && !st.IsDir() {
+		return "", fmt.Errorf("Impossible to inject into %s: not a directory", dir)
+	}
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return "", err
+	}
+	return dirPath, nil
+}
+
 // Inject the io.Reader at the given path. Note: do not close the reader
 func (container *Container) Inject(file io.Reader, pth string) error {
-	// Make sure the directory exists
-	if err := os.MkdirAll(path.Join(container.rwPath(), path.Dir(pth)), 0755); err != nil {
+	dir, err := container.injectDir(path.Dir(path.Clean("/" + pth)))
+	if err != nil {
 		return err
 	}
 	// FIXME: Handle permissions/already existing dest
-	dest, err := os.Create(path.Join(container.rwPath(), pth))
+	dest, err := os.OpenFile(path.Join(dir, path.Base(pth)), os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, 0666)
 	if err != nil {
 		return err
 	}
+	defer dest.Close()
 	if _, err := io.Copy(dest, file); err != nil {
 		return err
 	}
-	return nil
+	return container.runtime.idMappings.chownRoot(dest.Name())
+}
+
+// InjectArchive unpacks `archive` into the directory at `dst` inside the
+// container, creating it if necessary. Unlike Inject, it preserves the
+// ownership and permissions of the archived files.
+func (container *Container) InjectArchive(archive Archive, dst string) error {
+	dir, err := container.injectDir(dst)
+	if err != nil {
+		return err
+	}
+	return container.runtime.idMappings.Untar(archive, dir)
 }
 
 func (container *Container) Cmd() *exec.Cmd {
@@ -290,18 +565,6 @@ func (container *Container) SaveHostConfig(hostConfig *HostConfig) (err error) {
 	return ioutil.WriteFile(container.hostConfigPath(), data, 0666)
 }
 
-func (container *Container) generateLXCConfig() error {
-	fo, err := os.Create(container.lxcConfigPath())
-	if err != nil {
-		return err
-	}
-	defer fo.Close()
-	if err := LxcTemplateCompiled.Execute(fo, container); err != nil {
-		return err
-	}
-	return nil
-}
-
 func (container *Container) startPty() error {
 	ptyMaster, ptySlave, err := pty.Open()
 	if err != nil {
@@ -330,7 +593,7 @@ func (container *Container) startPty() error {
 			utils.Debugf("[startPty] End of stdin pipe")
 		}()
 	}
-	if err := container.cmd.Start(); err != nil {
+	if err := container.runtime.execDriver.Start(container, container.cmd); err != nil {
 		return err
 	}
 	ptySlave.Close()
@@ -352,7 +615,7 @@ func (container *Container) start() error {
 			utils.Debugf("End of stdin pipe [start]")
 		}()
 	}
-	return container.cmd.Start()
+	return container.runtime.execDriver.Start(container, container.cmd)
 }
 
 func (container *Container) Attach(stdin io.ReadCloser, stdinCloser io.Closer, stdout io.Writer, stderr io.Writer) chan error {
@@ -490,16 +753,28 @@ func (container *Container) Attach(stdin io.ReadCloser, stdinCloser io.Closer, s
 	})
 }
 
-func (container *Container) Start(hostConfig *HostConfig) error {
+func (container *Container) Start(hostConfig *HostConfig) (err error) {
 	container.State.Lock()
 	defer container.State.Unlock()
-	if len(hostConfig.Binds) == 0 {
+	// No host config means "start with the previous one"
+	if hostConfig == nil {
 		hostConfig, _ = container.ReadHostConfig()
 	}
 
 	if container.State.Running {
 		return fmt.Errorf("The container %s is already running.", container.ID)
 	}
+	// The volumes mounted by this run are unmounted if it fails
+	var mounted []*Volume
+	defer func() {
+		if err != nil {
+			for _, vol := range mounted {
+				container.unmountVolume(vol)
+			}
+			container.State.setError(err)
+			container.ToDisk()
+		}
+	}()
 	if err := container.EnsureMounted(); err != nil {
 		return err
 	}
@@ -507,6 +782,18 @@ func (container *Container) Start(hostConfig *HostConfig) error {
 		return err
 	}
 
+	// The execution drivers read the capabilities, the privileges and
+	// the security profiles of the container while starting it
+	capabilities, err := effectiveCapabilities(hostConfig)
+	if err != nil {
+		return err
+	}
+	container.Capabilities = capabilities
+	container.hostConfig = hostConfig
+	if err := container.setupSecurity(hostConfig); err != nil {
+		return err
+	}
+
 	// Make sure the config is compatible with the current kernel
 	if container.Config.Memory > 0 && !container.runtime.capabilities.MemoryLimit {
 		log.Printf("WARNING: Your kernel does not support memory limit capabilities. Limitation discarded.\n")
@@ -516,116 +803,244 @@ func (container *Container) Start(hostConfig *HostConfig) error {
 		log.Printf("WARNING: Your kernel does not support swap limit capabilities. Limitation discarded.\n")
 		container.Config.MemorySwap = -1
 	}
+	if container.Config.MemoryReservation > 0 && !container.runtime.capabilities.MemoryLimit {
+		log.Printf("WARNING: Your kernel does not support memory limit capabilities. Reservation discarded.\n")
+		container.Config.MemoryReservation = 0
+	}
+	if (container.Config.CpuQuota > 0 || container.Config.CpuPeriod > 0) && !container.runtime.capabilities.CpuCfsQuota {
+		log.Printf("WARNING: Your kernel does not support CPU quotas. Limitation discarded.\n")
+		container.Config.CpuQuota = 0
+		container.Config.CpuPeriod = 0
+	}
+	if (container.Config.CpusetCpus != "" || container.Config.CpusetMems != "") && !container.runtime.capabilities.Cpuset {
+		log.Printf("WARNING: Your kernel does not support cpusets. Limitation discarded.\n")
+		container.Config.CpusetCpus = ""
+		container.Config.CpusetMems = ""
+	}
+	if container.Config.BlkioWeight > 0 && !container.runtime.capabilities.BlkioWeight {
+		log.Printf("WARNING: Your kernel does not support block IO weights. Weight discarded.\n")
+		container.Config.BlkioWeight = 0
+	}
+	if (len(container.Config.BlkioDeviceReadBps) > 0 || len(container.Config.BlkioDeviceWriteBps) > 0) && !container.runtime.capabilities.BlkioThrottle {
+		log.Printf("WARNING: Your kernel does not support block IO throttling. Limitation discarded.\n")
+		container.Config.BlkioDeviceReadBps = nil
+		container.Config.BlkioDeviceWriteBps = nil
+	}
+	if container.Config.PidsLimit > 0 && !container.runtime.capabilities.PidsLimit {
+		log.Printf("WARNING: Your kernel does not support pids limits. Limitation discarded.\n")
+		container.Config.PidsLimit = 0
+	}
+	for _, specs := range [][]string{container.Config.BlkioDeviceReadBps, container.Config.BlkioDeviceWriteBps} {
+		for _, spec := range specs {
+			if _, _, err := parseThrottleDevice(spec); err != nil {
+				return err
+			}
+		}
+	}
 	container.Volumes = make(map[string]string)
 	container.VolumesRW = make(map[string]bool)
 
+	container.VolumesPropagation = make(map[string]string)
+	container.VolumeNames = make(map[string]string)
+
 	// Create the requested bind mounts
-	binds := make(map[string]BindMap)
-	// Define illegal container destinations
-	illegalDsts := []string{"/", "."}
-
-	for _, bind := range hostConfig.Binds {
-		// FIXME: factorize bind parsing in parseBind
-		var src, dst, mode string
-		arr := strings.Split(bind, ":")
-		if len(arr) == 2 {
-			src = arr[0]
-			dst = arr[1]
-			mode = "rw"
-		} else if len(arr) == 3 {
-			src = arr[0]
-			dst = arr[1]
-			mode = arr[2]
-		} else {
-			return fmt.Errorf("Invalid bind specification: %s", bind)
+	binds := make(map[string]*BindMap)
+	for _, spec := range hostConfig.Binds {
+		bind, err := parseBind(spec)
+		if err != nil {
+			return err
 		}
 
-		// Bail if trying to mount to an illegal destination
-		for _, illegal := range illegalDsts {
-			if dst == illegal {
-				return fmt.Errorf("Illegal bind destination: %s", dst)
+		// Sources which are not paths are named volumes, created on first use
+		if !path.IsAbs(bind.SrcPath) {
+			vol, err := container.runtime.volumes.Acquire(bind.SrcPath, hostConfig.VolumeDriver, container.ID)
+			if err != nil {
+				return err
+			}
+			if bind.SrcPath, err = container.runtime.volumes.Mount(vol); err != nil {
+				return err
 			}
+			mounted = append(mounted, vol)
+			container.VolumeNames[bind.DstPath] = vol.Name
 		}
-
-		bindMap := BindMap{
-			SrcPath: src,
-			DstPath: dst,
-			Mode:    mode,
+		if err := checkBindSource(bind); err != nil {
+			return err
 		}
-		binds[path.Clean(dst)] = bindMap
+		if bind.Relabel != "" {
+			if err := relabel(bind.SrcPath, bind.Relabel == "z", container.ID); err != nil {
+				return err
+			}
+		}
+		// Only the processes of the container can use its private files
+		if bind.Relabel == "Z" {
+			container.ProcessLabel = selinuxProcessLabel(container.ID)
+		}
+		binds[bind.DstPath] = bind
 	}
 
-	// FIXME: evaluate volumes-from before individual volumes, so that the latter can override the former.
-	// Create the requested volumes volumes
+	// The binds come first, then the volumes of the containers given to
+	// -volumes-from. The other volumes are new anonymous volumes.
 	for volPath := range container.Config.Volumes {
 		volPath = path.Clean(volPath)
-		// If an external bind is defined for this volume, use that as a source
-		if bindMap, exists := binds[volPath]; exists {
-			container.Volumes[volPath] = bindMap.SrcPath
-			if strings.ToLower(bindMap.Mode) == "rw" {
-				container.VolumesRW[volPath] = true
+		if bind, exists := binds[volPath]; exists {
+			container.Volumes[volPath] = bind.SrcPath
+			container.VolumesRW[volPath] = bind.Mode == "rw"
+			if bind.Propagation != "" {
+				container.VolumesPropagation[volPath] = bind.Propagation
 			}
-			// Otherwise create an directory in $ROOT/volumes/ and use that
-		} else {
-			c, err := container.runtime.volumes.Create(nil, container, "", "", nil)
+		}
+	}
+
+	if container.Config.VolumesFrom != "" {
+		// Containers whose volumes overlap can't be combined
+		inherited := make(map[string]string)
+		for _, spec := range strings.Split(container.Config.VolumesFrom, ",") {
+			name, mode, err := parseVolumesFrom(spec)
 			if err != nil {
 				return err
 			}
-			srcPath, err := c.layer()
-			if err != nil {
-				return err
+			c := container.runtime.Get(name)
+			if c == nil {
+				return fmt.Errorf("Container %s not found. Impossible to mount its volumes", name)
+			}
+			for volPath, src := range c.Volumes {
+				if from, exists := inherited[volPath]; exists {
+					return fmt.Errorf("The volume %s of the container %s overlaps the one of the container %s", volPath, utils.TruncateID(c.ID), utils.TruncateID(from))
+				}
+				inherited[volPath] = c.ID
+				if _, exists := container.Volumes[volPath]; exists {
+					continue
+				}
+				// Volumes of drivers are mounted for each container using them
+				if name, exists := c.VolumeNames[volPath]; exists {
+					vol, err := container.runtime.volumes.Get(name)
+					if err != nil {
+						return err
+					}
+					if src, err = container.runtime.volumes.Mount(vol); err != nil {
+						return err
+					}
+					mounted = append(mounted, vol)
+					container.VolumeNames[volPath] = name
+				}
+				container.Volumes[volPath] = src
+				switch mode {
+				case "ro":
+					container.VolumesRW[volPath] = false
+				case "rw":
+					container.VolumesRW[volPath] = true
+				default:
+					container.VolumesRW[volPath] = c.VolumesRW[volPath]
+				}
 			}
-			container.Volumes[volPath] = srcPath
-			container.VolumesRW[volPath] = true // RW by default
 		}
-		// Create the mountpoint
-		if err := os.MkdirAll(path.Join(container.RootfsPath(), volPath), 0755); err != nil {
-			return nil
+	}
+
+	for volPath := range container.Config.Volumes {
+		volPath = path.Clean(volPath)
+		if _, exists := container.Volumes[volPath]; exists {
+			continue
 		}
+		vol, err := container.runtime.volumes.Acquire("", hostConfig.VolumeDriver, container.ID)
+		if err != nil {
+			return err
+		}
+		mountpoint, err := container.runtime.volumes.Mount(vol)
+		if err != nil {
+			return err
+		}
+		mounted = append(mounted, vol)
+		container.VolumeNames[volPath] = vol.Name
+		container.Volumes[volPath] = mountpoint
+		container.VolumesRW[volPath] = true // RW by default
 	}
 
-	if container.Config.VolumesFrom != "" {
-		c := container.runtime.Get(container.Config.VolumesFrom)
-		if c == nil {
-			return fmt.Errorf("Container %s not found. Impossible to mount its volumes", container.ID)
+	// Create the mountpoints
+	for volPath := range container.Volumes {
+		if err := container.createMountpoint(path.Join(container.RootfsPath(), volPath), true); err != nil {
+			return err
 		}
-		for volPath, id := range c.Volumes {
-			if _, exists := container.Volumes[volPath]; exists {
-				return fmt.Errorf("The requested volume %s overlap one of the volume of the container %s", volPath, c.ID)
-			}
-			if err := os.MkdirAll(path.Join(container.RootfsPath(), volPath), 0755); err != nil {
-				return nil
-			}
-			container.Volumes[volPath] = id
+	}
+	if err := container.runtime.volumes.Reference(container); err != nil {
+		return err
+	}
+
+	if err := container.setupDevices(hostConfig); err != nil {
+		return err
+	}
+
+	if err := container.setupHostFiles(hostConfig); err != nil {
+		return err
+	}
+
+	// Create the mountpoints of the tmpfs filesystems
+	for target := range hostConfig.Tmpfs {
+		if _, _, err := parseTmpfs(target); err != nil {
+			return err
 		}
 	}
+	for _, tmpfs := range tmpfsMounts(container) {
+		if err := container.createMountpoint(path.Join(container.RootfsPath(), tmpfs.Target), true); err != nil {
+			return err
+		}
+	}
+
+	if err := container.setupControlDir(); err != nil {
+		return err
+	}
 
-	if err := container.generateLXCConfig(); err != nil {
+	if err := container.setupUserns(hostConfig); err != nil {
 		return err
 	}
 
-	params := []string{
-		"-n", container.ID,
-		"-f", container.lxcConfigPath(),
-		"--",
-		"/sbin/init",
+	// Nothing may be created in the root filesystem past this point
+	if hostConfig.ReadonlyRootfs {
+		if err := MountReadonly(container.RootfsPath()); err != nil {
+			return err
+		}
 	}
 
+	// Arguments of docker-init, which the execution driver runs as /sbin/init
+	params := []string{}
+
 	// Networking
-	params = append(params, "-g", container.network.Gateway.String())
+	if !container.network.disabled {
+		params = append(params, "-g", container.network.Gateway.String())
+	}
 
 	// User
 	if container.Config.User != "" {
 		params = append(params, "-u", container.Config.User)
 	}
 
+	// Working directory
+	if container.Config.WorkingDir != "" {
+		params = append(params, "-w", container.Config.WorkingDir)
+	}
+
+	// Resource limits
+	for _, u := range mergeUlimits(DefaultUlimits, hostConfig.Ulimits) {
+		if _, err := ParseUlimit(u.String()); err != nil {
+			return err
+		}
+		params = append(params, "-ulimit", u.String())
+	}
+
+	// Keep docker-init as PID 1
+	if hostConfig.Init {
+		params = append(params, "-init")
+	}
+
 	if container.Config.Tty {
 		params = append(params, "-e", "TERM=xterm")
 	}
 
-	// Setup environment
+	// Setup environment. When running as another user, HOME is
+	// taken from the container's passwd entry by docker-init.
+	if container.Config.User == "" {
+		params = append(params, "-e", "HOME=/")
+	}
 	params = append(params,
-		"-e", "HOME=/",
 		"-e", "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
 	)
 
@@ -637,7 +1052,7 @@ func (container *Container) Start(hostConfig *HostConfig) error {
 	params = append(params, "--", container.Path)
 	params = append(params, container.Args...)
 
-	container.cmd = exec.Command("lxc-start", params...)
+	container.cmd = exec.Command("/sbin/init", params...)
 
 	// Setup logging of stdout and stderr to disk
 	if err := container.runtime.LogToDisk(container.stdout, container.logPath("stdout")); err != nil {
@@ -647,7 +1062,6 @@ func (container *Container) Start(hostConfig *HostConfig) error {
 		return err
 	}
 
-	var err error
 	if container.Config.Tty {
 		err = container.startPty()
 	} else {
@@ -663,6 +1077,14 @@ func (container *Container) Start(hostConfig *HostConfig) error {
 	// Init the lock
 	container.waitLock = make(chan struct{})
 
+	// Only containers with a memory limit have an OOM killer of their own
+	if container.Config.Memory > 0 {
+		container.oomEvents = make(chan struct{}, 1)
+		go container.watchOOM(container.oomEvents)
+	} else {
+		container.oomEvents = nil
+	}
+
 	container.ToDisk()
 	container.SaveHostConfig(hostConfig)
 	go container.monitor()
@@ -731,6 +1153,9 @@ func (container *Container) allocateNetwork() error {
 		container.NetworkSettings.PortMapping[proto][backend] = frontend
 	}
 	container.network = iface
+	if iface.disabled {
+		return nil
+	}
 	container.NetworkSettings.Bridge = container.runtime.networkManager.bridgeIface
 	container.NetworkSettings.IPAddress = iface.IPNet.IP.String()
 	container.NetworkSettings.IPPrefixLen, _ = iface.IPNet.Mask.Size()
@@ -744,27 +1169,19 @@ func (container *Container) releaseNetwork() {
 	container.NetworkSettings = &NetworkSettings{}
 }
 
-// FIXME: replace this with a control socket within docker-init
-func (container *Container) waitLxc() error {
-	for {
-		output, err := exec.Command("lxc-info", "-n", container.ID).CombinedOutput()
-		if err != nil {
-			return err
-		}
-		if !strings.Contains(string(output), "RUNNING") {
-			return nil
-		}
-		time.Sleep(500 * time.Millisecond)
-	}
-}
-
 func (container *Container) monitor() {
 	// Wait for the program to exit
 	utils.Debugf("Waiting for process")
 
-	// If the command does not exists, try to wait via lxc
+	// If the command does not exists, ask docker-init for the exit status,
+	// or try to wait via the execution driver
+	exitCode := -1
+	signal := 0
+	fromInit := false
 	if container.cmd == nil {
-		if err := container.waitLxc(); err != nil {
+		if resp, err := container.control(&controlRequest{Action: "wait"}); err == nil {
+			exitCode, signal, fromInit = resp.ExitCode, resp.Signal, true
+		} else if exitCode, err = container.runtime.execDriver.Wait(container); err != nil {
 			utils.Debugf("%s: Process: %s", container.ID, err)
 		}
 	} else {
@@ -775,13 +1192,32 @@ func (container *Container) monitor() {
 	}
 	utils.Debugf("Process finished")
 
-	exitCode := -1
 	if container.cmd != nil {
-		exitCode = container.cmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
+		status := container.cmd.ProcessState.Sys().(syscall.WaitStatus)
+		if status.Signaled() {
+			signal = int(status.Signal())
+			exitCode = 128 + signal
+		} else {
+			exitCode = status.ExitStatus()
+		}
+	}
+	// lxc-start and docker-init exit with 128+n when the program was killed
+	// by the signal n
+	if !fromInit && signal == 0 && exitCode > 128 && exitCode <= 128+64 {
+		signal = exitCode - 128
+	}
+	// The OOM killer may have killed another process than the program
+	oomKilled := false
+	if container.Config.Memory > 0 {
+		oomKilled = container.oomKilled(signal == int(syscall.SIGKILL))
 	}
 
 	// Cleanup
+	if err := container.runtime.execDriver.Cleanup(container); err != nil {
+		utils.Debugf("%s: Error cleaning up: %s", container.ID, err)
+	}
 	container.releaseNetwork()
+	container.unmountVolumes()
 	if container.Config.OpenStdin {
 		if err := container.stdin.Close(); err != nil {
 			utils.Debugf("%s: Error close stdin: %s", container.ID, err)
@@ -810,6 +1246,8 @@ func (container *Container) monitor() {
 	}
 
 	// Report status back
+	container.State.Signal = signal
+	container.State.OOMKilled = oomKilled
 	container.State.setStopped(exitCode)
 
 	// Release the lock
@@ -826,23 +1264,98 @@ func (container *Container) monitor() {
 	}
 }
 
+// cgroupPath returns the directory of the container's cgroup for the given subsystem
+func (container *Container) cgroupPath(subsystem string) (string, error) {
+	mountpoint, err := utils.FindCgroupMountpoint(subsystem)
+	if err != nil {
+		return "", err
+	}
+	// Depending on its version, lxc nests its cgroups under "lxc" or not,
+	// the native driver nests them under "docker"
+	for _, dir := range []string{
+		path.Join(mountpoint, "lxc", container.ID),
+		path.Join(mountpoint, container.ID),
+		path.Join(mountpoint, nativeCgroupRoot, container.ID),
+	} {
+		if _, err := os.Stat(dir); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("No %s cgroup found for container %s", subsystem, container.ID)
+}
+
+// watchOOM forwards the OOM notifications of the container's memory cgroup
+// to `events`, and closes it once the cgroup is gone
+func (container *Container) watchOOM(events chan struct{}) {
+	defer close(events)
+	// The cgroup is created shortly after the execution driver is started
+	var dir string
+	for retries := 0; ; retries++ {
+		var err error
+		if dir, err = container.cgroupPath("memory"); err == nil {
+			break
+		}
+		if retries == 50 {
+			utils.Debugf("%s: Unable to watch for OOM: %s", container.ID, err)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	notifications, err := notifyOnOOM(dir)
+	if err != nil {
+		utils.Debugf("%s: Unable to watch for OOM: %s", container.ID, err)
+		return
+	}
+	for _ = range notifications {
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// oomKilled returns true if the OOM killer killed a process of the
+// container, which exited. The notification of the kill may still be on its
+// way if the program was killed, which is waited for if `killed` is set.
+func (container *Container) oomKilled(killed bool) bool {
+	// The kernel counts the kills in the memory cgroup, if it is still around
+	if dir, err := container.cgroupPath("memory"); err == nil {
+		if oomKilled, err := memoryCgroupOOMKilled(dir); err == nil {
+			return oomKilled
+		}
+	}
+	if !killed {
+		select {
+		case _, ok := <-container.oomEvents:
+			return ok
+		default:
+			return false
+		}
+	}
+	select {
+	case _, ok := <-container.oomEvents:
+		return ok
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
 func (container *Container) kill() error {
 	if !container.State.Running {
 		return nil
 	}
 
-	// Sending SIGKILL to the process via lxc
-	output, err := exec.Command("lxc-kill", "-n", container.ID, "9").CombinedOutput()
-	if err != nil {
-		log.Printf("error killing container %s (%s, %s)", container.ID, output, err)
+	// Sending SIGKILL to the process via docker-init or the execution driver
+	if err := container.signal(9); err != nil {
+		log.Printf("error killing container %s (%s)", container.ID, err)
 	}
 
 	// 2. Wait for the process to die, in last resort, try to kill the process directly
 	if err := container.WaitTimeout(10 * time.Second); err != nil {
 		if container.cmd == nil {
-			return fmt.Errorf("lxc-kill failed, impossible to kill the container %s", container.ID)
+			return fmt.Errorf("%s failed to kill the container %s", container.runtime.execDriver.Name(), container.ID)
 		}
-		log.Printf("Container %s failed to exit within 10 seconds of lxc SIGKILL - trying direct SIGKILL", container.ID)
+		log.Printf("Container %s failed to exit within 10 seconds of SIGKILL - trying direct SIGKILL", container.ID)
 		if err := container.cmd.Process.Kill(); err != nil {
 			return err
 		}
@@ -853,6 +1366,15 @@ func (container *Container) kill() error {
 	return nil
 }
 
+// signal delivers sig to the program of the container, through docker-init
+// when it is resident or the execution driver otherwise
+func (container *Container) signal(sig int) error {
+	if _, err := container.control(&controlRequest{Action: "signal", Signal: sig}); err == nil {
+		return nil
+	}
+	return container.runtime.execDriver.Kill(container, sig)
+}
+
 func (container *Container) Kill() error {
 	container.State.Lock()
 	defer container.State.Unlock()
@@ -869,12 +1391,14 @@ func (container *Container) Stop(seconds int) error {
 		return nil
 	}
 
-	// 1. Send a SIGTERM
-	if output, err := exec.Command("lxc-kill", "-n", container.ID, "15").CombinedOutput(); err != nil {
-		log.Print(string(output))
-		log.Print("Failed to send SIGTERM to the process, force killing")
-		if err := container.kill(); err != nil {
-			return err
+	// 1. Send a SIGTERM, docker-init takes care of killing everything after the timeout
+	if _, err := container.control(&controlRequest{Action: "shutdown", Timeout: seconds}); err != nil {
+		if err := container.signal(15); err != nil {
+			log.Print(err)
+			log.Print("Failed to send SIGTERM to the process, force killing")
+			if err := container.kill(); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -892,13 +1416,84 @@ func (container *Container) Restart(seconds int) error {
 	if err := container.Stop(seconds); err != nil {
 		return err
 	}
-	hostConfig := &HostConfig{}
-	if err := container.Start(hostConfig); err != nil {
+	if err := container.Start(nil); err != nil {
 		return err
 	}
 	return nil
 }
 
+// Update changes the resource limits of the container. They are written to
+// its cgroups if it is running, and saved with its config for the next starts.
+func (container *Container) Update(update *UpdateConfig) error {
+	container.State.Lock()
+	defer container.State.Unlock()
+
+	config := *container.Config
+	if update.Memory != 0 {
+		config.Memory = update.Memory
+	}
+	if update.MemorySwap != 0 {
+		config.MemorySwap = update.MemorySwap
+	}
+	if update.CpuShares != 0 {
+		config.CpuShares = update.CpuShares
+	}
+	if update.CpusetCpus != "" {
+		config.CpusetCpus = update.CpusetCpus
+	}
+	if update.CpusetMems != "" {
+		config.CpusetMems = update.CpusetMems
+	}
+	if update.BlkioWeight != 0 {
+		config.BlkioWeight = update.BlkioWeight
+	}
+
+	if config.Memory < 0 || config.CpuShares < 0 {
+		return fmt.Errorf("Bad parameter: the limits can't be negative")
+	}
+	if config.BlkioWeight != 0 && (config.BlkioWeight < 10 || config.BlkioWeight > 1000) {
+		return fmt.Errorf("Bad parameter: the block IO weight must be between 10 and 1000")
+	}
+	if config.Memory > 0 && config.MemoryReservation > config.Memory {
+		return fmt.Errorf("Bad parameter: the memory limit can't be lower than the memory reservation")
+	}
+	if update.MemorySwap != 0 && config.Memory == 0 {
+		return fmt.Errorf("Bad parameter: the memory+swap limit needs a memory limit")
+	}
+	capabilities := container.runtime.capabilities
+	if (update.Memory != 0 && !capabilities.MemoryLimit) ||
+		(update.MemorySwap != 0 && !capabilities.SwapLimit) ||
+		((update.CpusetCpus != "" || update.CpusetMems != "") && !capabilities.Cpuset) ||
+		(update.BlkioWeight != 0 && !capabilities.BlkioWeight) {
+		return fmt.Errorf("Impossible to update the container %s: your kernel does not support these limits", container.ShortID())
+	}
+
+	if container.State.Running {
+		settings := updateSettings(container.Config, &config, update, capabilities.SwapLimit)
+		if utils.IsCgroupUnified() {
+			settings = toUnified(settings)
+		}
+		// Find every cgroup first, so that the update is not half applied
+		dirs := make([]string, len(settings))
+		for i, setting := range settings {
+			dir, err := container.cgroupPath(setting.Subsystem)
+			if err != nil {
+				// The native driver only creates the cgroups of the limits
+				// the container was started with
+				return fmt.Errorf("Impossible to update the container %s: it was started without a %s cgroup. Update it while it is stopped to change its %s limits.", container.ShortID(), setting.Subsystem, setting.Subsystem)
+			}
+			dirs[i] = dir
+		}
+		for i, setting := range settings {
+			if err := ioutil.WriteFile(path.Join(dirs[i], setting.Key), []byte(setting.Value), 0644); err != nil {
+				return fmt.Errorf("Unable to set %s to %s: %s", setting.Key, setting.Value, err)
+			}
+		}
+	}
+	container.Config = &config
+	return container.ToDisk()
+}
+
 // Wait blocks until the container stops running, then returns its exit code.
 func (container *Container) Wait() int {
 	<-container.waitLock
@@ -913,12 +1508,14 @@ func (container *Container) Resize(h, w int) error {
 	return term.SetWinsize(pty.Fd(), &term.Winsize{Height: uint16(h), Width: uint16(w)})
 }
 
+// ExportRw returns an archive of the changes of the container, without the
+// mount point of the docker-init control directory
 func (container *Container) ExportRw() (Archive, error) {
-	return Tar(container.rwPath(), Uncompressed)
+	return container.runtime.idMappings.TarExclude(container.rwPath(), Uncompressed, []string{"." + controlDir})
 }
 
 func (container *Container) RwChecksum() (string, error) {
-	rwData, err := Tar(container.rwPath(), Xz)
+	rwData, err := container.runtime.idMappings.TarExclude(container.rwPath(), Xz, []string{"." + controlDir})
 	if err != nil {
 		return "", err
 	}
@@ -929,7 +1526,60 @@ func (container *Container) Export() (Archive, error) {
 	if err := container.EnsureMounted(); err != nil {
 		return nil, err
 	}
-	return Tar(container.RootfsPath(), Uncompressed)
+	return container.runtime.idMappings.Tar(container.RootfsPath(), Uncompressed)
+}
+
+// hostPath returns the path on the host of `resource` inside the container,
+// taking the container's volumes into account. The symlinks are resolved
+// as the container sees them: they never lead outside of its rootfs or of
+// the volume they are in. The rootfs must be mounted.
+func (container *Container) hostPath(resource string) (string, error) {
+	resource = path.Clean("/" + resource)
+	rootfs := container.RootfsPath()
+	resolved, err := utils.FollowSymlinkInScope(path.Join(rootfs, resource), rootfs)
+	if err != nil {
+		return "", err
+	}
+	resource = "/" + strings.TrimPrefix(strings.TrimPrefix(resolved, rootfs), "/")
+
+	var (
+		volPath string
+		srcPath string
+	)
+	for v, src := range container.Volumes {
+		// Volumes can be nested: the longest matching volume wins
+		if (resource == v || strings.HasPrefix(resource, v+"/")) && len(v) > len(volPath) {
+			volPath, srcPath = v, src
+		}
+	}
+	if volPath != "" {
+		return utils.FollowSymlinkInScope(path.Join(srcPath, strings.TrimPrefix(resource, volPath)), srcPath)
+	}
+	return resolved, nil
+}
+
+// Copy returns a tar archive of the file or directory at `resource` inside
+// the container. The archive contains a single top-level entry named after
+// the base name of `resource`, which is archived as is if it is a symlink.
+func (container *Container) Copy(resource string) (Archive, error) {
+	if err := container.EnsureMounted(); err != nil {
+		return nil, err
+	}
+	resource = path.Clean("/" + resource)
+	src, err := container.hostPath(path.Dir(resource))
+	if err != nil {
+		return nil, err
+	}
+	if resource != "/" {
+		src = path.Join(src, path.Base(resource))
+	}
+	if _, err := os.Lstat(src); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("No such file or directory: %s", resource)
+		}
+		return nil, err
+	}
+	return container.runtime.idMappings.TarFilter(path.Dir(src), Uncompressed, []string{path.Base(src)})
 }
 
 func (container *Container) WaitTimeout(timeout time.Duration) error {
@@ -969,7 +1619,60 @@ func (container *Container) Changes() ([]Change, error) {
 	if err != nil {
 		return nil, err
 	}
-	return image.Changes(container.rwPath())
+	changes, err := image.Changes(container.rwPath())
+	if err != nil {
+		return nil, err
+	}
+	return excludeMountpoints(changes, container.Mountpoints), nil
+}
+
+// createMountpoint creates `target`, a path of the host in the root
+// filesystem, as a directory or as an empty file if it doesn't exist. The
+// files it creates are recorded as mountpoints.
+func (container *Container) createMountpoint(target string, dir bool) error {
+	rootfs := container.RootfsPath()
+	var created []string
+	for p := target; p != rootfs && p != "/"; p = path.Dir(p) {
+		if _, err := os.Lstat(p); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		created = append(created, p)
+	}
+	if dir {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+	} else if len(created) > 0 {
+		if err := os.MkdirAll(path.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, []byte{}, 0644); err != nil {
+			return err
+		}
+	}
+	for _, p := range created {
+		mountpoint := "/" + strings.TrimPrefix(p, rootfs+"/")
+		exists := false
+		for _, m := range container.Mountpoints {
+			exists = exists || m == mountpoint
+		}
+		if !exists {
+			container.Mountpoints = append(container.Mountpoints, mountpoint)
+		}
+	}
+	return nil
+}
+
+// readonlyRootfs returns true if the container was last started with a
+// read-only root filesystem
+func (container *Container) readonlyRootfs() bool {
+	hostConfig := container.hostConfig
+	if hostConfig == nil {
+		hostConfig, _ = container.ReadHostConfig()
+	}
+	return hostConfig.ReadonlyRootfs
 }
 
 func (container *Container) GetImage() (*Image, error) {
@@ -1011,10 +1714,6 @@ func (container *Container) jsonPath() string {
 	return path.Join(container.root, "config.json")
 }
 
-func (container *Container) lxcConfigPath() string {
-	return path.Join(container.root, "config.lxc")
-}
-
 // This method must be exported to be used from the lxc template
 func (container *Container) RootfsPath() string {
 	return path.Join(container.root, "rootfs")
@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -601,6 +602,147 @@ func TestKill(t *testing.T) {
 	}
 }
 
+func TestInitProcess(t *testing.T) {
+	runtime := mkRuntime(t)
+	defer nuke(runtime)
+
+	builder := NewBuilder(runtime)
+
+	// The exit code of the program is the one of the container
+	container, err := builder.Create(&Config{
+		Image: GetTestImage(runtime).ID,
+		Cmd:   []string{"sh", "-c", "exit 3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+	if err := container.Start(&HostConfig{Init: true}); err != nil {
+		t.Fatal(err)
+	}
+	if exitCode := container.Wait(); exitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", exitCode)
+	}
+
+	// SIGTERM is forwarded to a program which doesn't handle it itself
+	container, err = builder.Create(&Config{
+		Image: GetTestImage(runtime).ID,
+		Cmd:   []string{"sleep", "60"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+	if err := container.Start(&HostConfig{Init: true}); err != nil {
+		t.Fatal(err)
+	}
+	// Give some time to lxc to spawn the process
+	container.WaitTimeout(500 * time.Millisecond)
+
+	start := time.Now()
+	if err := container.Stop(10); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Stopping the container took %s, SIGTERM was not forwarded", elapsed)
+	}
+	if container.State.ExitCode != 128+int(syscall.SIGTERM) {
+		t.Errorf("Expected exit code %d, got %d", 128+int(syscall.SIGTERM), container.State.ExitCode)
+	}
+}
+
+func TestNativeDriver(t *testing.T) {
+	runtime := mkRuntime(t)
+	defer nuke(runtime)
+
+	driver, err := newNativeDriver()
+	if err != nil {
+		t.Skip(err)
+	}
+	runtime.execDriver = driver
+
+	container, err := NewBuilder(runtime).Create(&Config{
+		Image:    GetTestImage(runtime).ID,
+		Cmd:      []string{"sh", "-c", "hostname; echo $$"},
+		Hostname: "nativehost",
+		Memory:   33554432,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+	output, err := container.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if container.State.ExitCode != 0 {
+		t.Fatalf("Unexpected exit code %d: %s", container.State.ExitCode, output)
+	}
+	lines := strings.Split(string(output), "\n")
+	if lines[0] != "nativehost" {
+		t.Errorf("Expected hostname nativehost, got %s", lines[0])
+	}
+	// The program runs in its own pid namespace
+	if lines[1] != "1" {
+		t.Errorf("Expected the program to be PID 1, got %s", lines[1])
+	}
+	// The cgroups are removed with the container
+	if pids, err := cgroupPids(nativeCgroupRoot, container.ID, "devices"); err == nil {
+		t.Errorf("The devices cgroup should have been removed, it contains %v", pids)
+	}
+}
+
+func TestFakeDriver(t *testing.T) {
+	runtime := mkRuntime(t)
+	defer nuke(runtime)
+	runtime.execDriver = newFakeDriver()
+
+	container, err := NewBuilder(runtime).Create(&Config{
+		Image:      GetTestImage(runtime).ID,
+		Cmd:        []string{"sh", "-c", "echo $FOO; exit 42"},
+		Env:        []string{"FOO=bar"},
+		WorkingDir: "/fake",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+	output, err := container.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "bar\n" {
+		t.Errorf("Expected bar, got %q", output)
+	}
+	if container.State.ExitCode != 42 {
+		t.Errorf("Expected exit code 42, got %d", container.State.ExitCode)
+	}
+
+	// Long running programs can be stopped
+	container, err = NewBuilder(runtime).Create(&Config{
+		Image: GetTestImage(runtime).ID,
+		Cmd:   []string{"sleep", "60"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+	if err := container.Start(&HostConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := runtime.execDriver.Info(container); err != nil {
+		t.Fatal(err)
+	} else if !info.Running {
+		t.Fatal("The fake driver should report the container as running")
+	}
+	if err := container.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	if container.State.Running {
+		t.Errorf("Container shouldn't be running")
+	}
+}
+
 func TestExitCode(t *testing.T) {
 	runtime := mkRuntime(t)
 	defer nuke(runtime)
@@ -638,6 +780,61 @@ func TestExitCode(t *testing.T) {
 	}
 }
 
+func TestStartError(t *testing.T) {
+	runtime := mkRuntime(t)
+	defer nuke(runtime)
+	container, err := NewBuilder(runtime).Create(&Config{
+		Image: GetTestImage(runtime).ID,
+		Cmd:   []string{"/bin/true"},
+	},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+
+	hostConfig := &HostConfig{Binds: []string{"/tmp"}}
+	if err := container.Start(hostConfig); err == nil {
+		t.Fatal("Container with an invalid bind should not start")
+	}
+	if container.State.Error == "" {
+		t.Errorf("The start failure should be recorded in the state")
+	}
+	if !strings.HasPrefix(container.State.String(), "Error") {
+		t.Errorf("Unexpected state %s", container.State.String())
+	}
+}
+
+func TestOOMKilled(t *testing.T) {
+	runtime := mkRuntime(t)
+	defer nuke(runtime)
+	if !runtime.capabilities.MemoryLimit {
+		t.Skip("Memory limits are not supported")
+	}
+	container, err := NewBuilder(runtime).Create(&Config{
+		Image:  GetTestImage(runtime).ID,
+		Cmd:    []string{"/bin/sh", "-c", "x=a; while true; do x=$x$x; done"},
+		Memory: 33554432,
+	},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+	if err := container.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !container.State.OOMKilled {
+		t.Errorf("Container should have been OOM killed (exit code %d)", container.State.ExitCode)
+	}
+	if container.State.FinishedAt.Before(container.State.StartedAt) {
+		t.Errorf("FinishedAt should be set when the container stops")
+	}
+	if !strings.Contains(container.State.String(), "OOM") {
+		t.Errorf("Unexpected state %s", container.State.String())
+	}
+}
+
 func TestRestart(t *testing.T) {
 	runtime := mkRuntime(t)
 	defer nuke(runtime)
@@ -849,6 +1046,26 @@ func TestUser(t *testing.T) {
 	if !strings.Contains(string(output), "uid=1(daemon) gid=1(daemon)") {
 		t.Error(string(output))
 	}
+
+	// Set a user and a group by id
+	container, err = builder.Create(&Config{
+		Image: GetTestImage(runtime).ID,
+		Cmd:   []string{"id"},
+
+		User: "1:0",
+	},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.Destroy(container)
+	output, err = container.Output()
+	if err != nil || container.State.ExitCode != 0 {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "uid=1(daemon) gid=0(root)") {
+		t.Error(string(output))
+	}
 }
 
 func TestMultipleContainers(t *testing.T) {
@@ -1113,11 +1330,12 @@ func TestLXCConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer runtime.Destroy(container)
-	container.generateLXCConfig()
-	grepFile(t, container.lxcConfigPath(), "lxc.utsname = foobar")
-	grepFile(t, container.lxcConfigPath(),
+	driver := newLxcDriver()
+	driver.generateConfig(container)
+	grepFile(t, driver.configPath(container), "lxc.utsname = foobar")
+	grepFile(t, driver.configPath(container),
 		fmt.Sprintf("lxc.cgroup.memory.limit_in_bytes = %d", mem))
-	grepFile(t, container.lxcConfigPath(),
+	grepFile(t, driver.configPath(container),
 		fmt.Sprintf("lxc.cgroup.memory.memsw.limit_in_bytes = %d", mem*2))
 }
 
@@ -1224,8 +1442,179 @@ func TestBindMounts(t *testing.T) {
 	readFile(path.Join(tmpDir, "holla"), t) // Will fail if the file doesn't exist
 
 	// test mounting to an illegal destination directory
-	if _, err := runContainer(r, []string{"-b", fmt.Sprintf("%s:.", tmpDir), "ls", "."}, nil); err == nil {
+	if _, _, _, err := ParseRun([]string{"-b", fmt.Sprintf("%s:.", tmpDir), "_", "ls", "."}, nil); err == nil {
 		t.Fatal("Container bind mounted illegal directory")
+	}
+}
+
+func TestParseVolumesFrom(t *testing.T) {
+	for spec, expected := range map[string][2]string{
+		"db":    {"db", ""},
+		"db:ro": {"db", "ro"},
+		"db:rw": {"db", "rw"},
+	} {
+		name, mode, err := parseVolumesFrom(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != expected[0] || mode != expected[1] {
+			t.Fatalf("%s: expected %v, got %s %s", spec, expected, name, mode)
+		}
+	}
+	for _, spec := range []string{"", ":ro", "db:rwm", "db:ro:rw"} {
+		if _, _, err := parseVolumesFrom(spec); err == nil {
+			t.Fatalf("%s should be invalid", spec)
+		}
+	}
+}
+
+func TestVolumesFrom(t *testing.T) {
+	r := mkRuntime(t)
+	defer nuke(r)
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	data, hc := mkContainer(r, []string{"-v", "/data", "-b", fmt.Sprintf("%s:/ro:ro", tmpDir), "_", "true"}, t)
+	defer r.Destroy(data)
+	if err := data.Start(hc); err != nil {
+		t.Fatal(err)
+	}
+	data.Wait()
+	logs, hc := mkContainer(r, []string{"-v", "/logs", "_", "true"}, t)
+	defer r.Destroy(logs)
+	if err := logs.Start(hc); err != nil {
+		t.Fatal(err)
+	}
+	logs.Wait()
 
+	// Volumes from several containers, with the mode of some overridden
+	c, hc := mkContainer(r, []string{"-volumes-from", data.ID + ":ro", "-volumes-from", logs.ID, "_", "true"}, t)
+	defer r.Destroy(c)
+	if err := c.Start(hc); err != nil {
+		t.Fatal(err)
+	}
+	c.Wait()
+	if c.Volumes["/data"] != data.Volumes["/data"] || c.Volumes["/logs"] != logs.Volumes["/logs"] {
+		t.Fatalf("The volumes weren't inherited: %v", c.Volumes)
+	}
+	if c.VolumesRW["/data"] || c.VolumesRW["/ro"] || !c.VolumesRW["/logs"] {
+		t.Fatalf("Unexpected modes of the inherited volumes: %v", c.VolumesRW)
+	}
+
+	// Binds take priority over the inherited volumes
+	c, hc = mkContainer(r, []string{"-volumes-from", data.ID, "-b", fmt.Sprintf("%s:/data:rw", tmpDir), "_", "true"}, t)
+	defer r.Destroy(c)
+	if err := c.Start(hc); err != nil {
+		t.Fatal(err)
+	}
+	c.Wait()
+	if c.Volumes["/data"] != tmpDir || !c.VolumesRW["/data"] || c.Volumes["/ro"] != tmpDir || c.VolumesRW["/ro"] {
+		t.Fatalf("Unexpected volumes: %v %v", c.Volumes, c.VolumesRW)
+	}
+
+	// Inherited volumes can't overlap
+	c, hc = mkContainer(r, []string{"-volumes-from", data.ID, "-volumes-from", data.ID, "_", "true"}, t)
+	defer r.Destroy(c)
+	if err := c.Start(hc); err == nil {
+		t.Fatal("Inheriting overlapping volumes should fail")
+	}
+}
+
+func TestReadonlyRootfs(t *testing.T) {
+	if syscall.Geteuid() != 0 {
+		t.Skip("Read-only mounts need root privileges")
+	}
+	r := mkRuntime(t)
+	defer nuke(r)
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	// Writing to the root filesystem fails, but not to a rw bind mount
+	stdout, _ := runContainer(r, []string{"-read-only", "-b", fmt.Sprintf("%s:/tmp:rw", tmpDir), "_", "sh", "-c", "touch /foo || echo failed; touch /tmp/holla"}, t)
+	if !strings.Contains(stdout, "failed") {
+		t.Fatalf("The root filesystem should be read-only, got %q", stdout)
+	}
+	readFile(path.Join(tmpDir, "holla"), t) // Will fail if the file doesn't exist
+}
+
+func TestReadonlyRootfsCommit(t *testing.T) {
+	r := mkRuntime(t)
+	defer nuke(r)
+	container, err := NewBuilder(r).Create(&Config{
+		Image: GetTestImage(r).ID,
+		Cmd:   []string{"true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Destroy(container)
+	if err := container.SaveHostConfig(&HostConfig{ReadonlyRootfs: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := container.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("A read-only container shouldn't have changes, got %v", changes)
+	}
+	if _, err := NewBuilder(r).Commit(container, "", "", "", "", nil); err == nil {
+		t.Fatalf("Committing a read-only container should fail")
+	}
+}
+
+func TestCreateMountpoint(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-test-mountpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	container := &Container{root: root}
+	rootfs := container.RootfsPath()
+	if err := os.MkdirAll(path.Join(rootfs, "var"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := container.createMountpoint(path.Join(rootfs, "var/lib/data"), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := container.createMountpoint(path.Join(rootfs, "dev/mynull"), false); err != nil {
+		t.Fatal(err)
+	}
+	// Existing files aren't mountpoints of their own
+	if err := container.createMountpoint(path.Join(rootfs, "var"), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := container.createMountpoint(path.Join(rootfs, "var/lib/data"), true); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"/var/lib/data", "/var/lib", "/dev/mynull", "/dev"}
+	if strings.Join(container.Mountpoints, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Expected the mountpoints %v, got %v", expected, container.Mountpoints)
+	}
+	if fi, err := os.Stat(path.Join(rootfs, "dev/mynull")); err != nil || !fi.Mode().IsRegular() {
+		t.Fatalf("Expected an empty file to be created: %v", err)
+	}
+}
+
+func TestExcludeMountpoints(t *testing.T) {
+	changes := []Change{
+		{"/var", ChangeModify},
+		{"/var/lib", ChangeAdd},
+		{"/var/lib/data", ChangeAdd},
+		{"/var/log", ChangeAdd},
+		{"/etc", ChangeModify},
+		{"/etc/hosts", ChangeDelete},
+	}
+	filtered := excludeMountpoints(changes, []string{"/var/lib/data", "/var/lib", "/etc/hosts"})
+	expected := []Change{{"/var/log", ChangeAdd}, {"/etc/hosts", ChangeDelete}}
+	if len(filtered) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, filtered)
+	}
+	for i := range expected {
+		if filtered[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, filtered)
+		}
 	}
 }
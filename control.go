@@ -0,0 +1,274 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// When docker-init stays resident as PID 1 of a container (see HostConfig.Init),
+// it serves a control socket through which the daemon can signal the program,
+// wait for its exit status, check that it is still alive and shut it down,
+// without going through the lxc tools.
+//
+// The socket lives in a per-container directory of the host which is bind
+// mounted at /.dockerinit inside the container. Each connection carries a
+// single JSON encoded controlRequest, answered by a single controlResponse.
+//
+// As root in the container can replace the socket, the daemon only talks to
+// a peer which is the PID 1 of the container.
+
+const (
+	controlDir    = "/.dockerinit"
+	controlSocket = "control.sock"
+)
+
+type controlRequest struct {
+	Action  string // One of "ping", "signal", "wait" or "shutdown"
+	Signal  int    // Signal to deliver for "signal"
+	Timeout int    // Seconds to wait after SIGTERM before killing everything for "shutdown"
+}
+
+type controlResponse struct {
+	Pid      int
+	Running  bool
+	ExitCode int
+	Signal   int // Signal which killed the program, 0 if it exited
+	Error    string
+}
+
+// Daemon side
+
+// InitDirPath returns the host directory mounted at /.dockerinit in the container
+func (container *Container) InitDirPath() string {
+	return path.Join(container.root, "init")
+}
+
+func (container *Container) controlSocketPath() string {
+	return path.Join(container.InitDirPath(), controlSocket)
+}
+
+// setupControlDir prepares the control directory before the container starts,
+// removing any socket left over by a previous run
+func (container *Container) setupControlDir() error {
+	if err := os.MkdirAll(container.InitDirPath(), 0700); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Join(container.RootfsPath(), controlDir), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(container.controlSocketPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// control sends a request to docker-init and returns its response. It fails
+// if the container was not started with a resident docker-init.
+func (container *Container) control(req *controlRequest) (*controlResponse, error) {
+	conn, err := net.DialTimeout("unix", container.controlSocketPath(), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	pid, err := controlPeerPid(conn.(*net.UnixConn))
+	if err != nil {
+		return nil, err
+	}
+	if initPid, err := container.initPid(); err != nil || pid != initPid {
+		return nil, fmt.Errorf("The control socket of %s is not served by its docker-init", container.ShortID())
+	}
+	return sendControl(conn, req)
+}
+
+// sendControl sends a request on a connection to docker-init and returns
+// its response
+func sendControl(conn net.Conn, req *controlRequest) (*controlResponse, error) {
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	resp := &controlResponse{}
+	if err := json.NewDecoder(conn).Decode(resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("docker-init: %s", resp.Error)
+	}
+	return resp, nil
+}
+
+// hasControl returns true if a docker-init is answering on the control socket
+func (container *Container) hasControl() bool {
+	resp, err := container.control(&controlRequest{Action: "ping"})
+	return err == nil && resp.Running
+}
+
+// initPid returns the pid of the PID 1 of the container, found among the
+// processes of its cgroup
+func (container *Container) initPid() (int, error) {
+	dir, err := container.cgroupPath("freezer")
+	if err != nil {
+		return 0, err
+	}
+	data, err := ioutil.ReadFile(path.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		pid, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil {
+			continue
+		}
+		if isInit, err := isNamespaceInit(pid); err != nil {
+			return 0, err
+		} else if isInit {
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("No init process found for container %s", container.ShortID())
+}
+
+// errNoNSpid is returned by initPid when the kernel doesn't tell the pids of
+// processes in their namespaces (before linux 4.1)
+var errNoNSpid = errors.New("The kernel doesn't report the pids of processes in their namespaces")
+
+// isNamespaceInit returns true if pid is the PID 1 of a pid namespace right
+// below ours. The PID 1 of a namespace nested in a container doesn't count.
+func isNamespaceInit(pid int) (bool, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		// The process exited
+		return false, nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "NSpid:" {
+			return len(fields) == 3 && fields[2] == "1", nil
+		}
+	}
+	return false, errNoNSpid
+}
+
+// Init side
+
+type initServer struct {
+	pid int
+
+	sync.Mutex
+	exitCode int
+	signal   int
+	exiting  bool
+	done     chan struct{}
+	inflight sync.WaitGroup
+}
+
+func newInitServer(pid int) *initServer {
+	return &initServer{
+		pid:  pid,
+		done: make(chan struct{}),
+	}
+}
+
+// listen serves the control socket at socketPath. It doesn't return.
+func (srv *initServer) listen(socketPath string) {
+	os.Remove(socketPath)
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		utils.Debugf("Unable to listen on %s: %s", socketPath, err)
+		return
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			utils.Debugf("Unable to accept control connection: %s", err)
+			continue
+		}
+		srv.Lock()
+		if srv.exiting {
+			srv.Unlock()
+			conn.Close()
+			continue
+		}
+		srv.inflight.Add(1)
+		srv.Unlock()
+		go func() {
+			defer srv.inflight.Done()
+			defer conn.Close()
+			srv.serve(conn)
+		}()
+	}
+}
+
+func (srv *initServer) serve(conn net.Conn) {
+	req := &controlRequest{}
+	if err := json.NewDecoder(conn).Decode(req); err != nil {
+		return
+	}
+	resp := &controlResponse{Pid: srv.pid}
+	switch req.Action {
+	case "ping":
+	case "signal":
+		if err := syscall.Kill(srv.pid, syscall.Signal(req.Signal)); err != nil {
+			resp.Error = err.Error()
+		}
+	case "wait":
+		<-srv.done
+	case "shutdown":
+		if err := syscall.Kill(srv.pid, syscall.SIGTERM); err != nil {
+			resp.Error = err.Error()
+			break
+		}
+		go func() {
+			select {
+			case <-srv.done:
+			case <-time.After(time.Duration(req.Timeout) * time.Second):
+				// Kill every process of the container but ourselves
+				syscall.Kill(-1, syscall.SIGKILL)
+			}
+		}()
+	default:
+		resp.Error = fmt.Sprintf("Unknown action: %s", req.Action)
+	}
+
+	select {
+	case <-srv.done:
+		srv.Lock()
+		resp.ExitCode = srv.exitCode
+		resp.Signal = srv.signal
+		srv.Unlock()
+	default:
+		resp.Running = true
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// finish records the exit status of the program, and the signal which killed
+// it if any, and gives pending requests a chance to be answered
+func (srv *initServer) finish(exitCode, signal int) {
+	srv.Lock()
+	srv.exitCode = exitCode
+	srv.signal = signal
+	srv.exiting = true
+	srv.Unlock()
+	close(srv.done)
+
+	flushed := make(chan struct{})
+	go func() {
+		srv.inflight.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+	}
+}
@@ -0,0 +1,10 @@
+package docker
+
+import (
+	"errors"
+	"net"
+)
+
+func controlPeerPid(conn *net.UnixConn) (int, error) {
+	return 0, errors.New("Peer credentials are not implemented on darwin")
+}
@@ -0,0 +1,21 @@
+package docker
+
+import (
+	"net"
+	"syscall"
+)
+
+// controlPeerPid returns the pid of the process listening at the other end
+// of conn, as seen from the daemon
+func controlPeerPid(conn *net.UnixConn) (int, error) {
+	f, err := conn.File()
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	cred, err := syscall.GetsockoptUcred(int(f.Fd()), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	if err != nil {
+		return 0, err
+	}
+	return int(cred.Pid), nil
+}
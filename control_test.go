@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestControlSocket(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-test-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	container := &Container{ID: GenerateID(), root: root}
+	if err := os.MkdirAll(container.InitDirPath(), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without docker-init, there is nobody to talk to
+	if container.hasControl() {
+		t.Fatal("hasControl should be false without a control socket")
+	}
+
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	srv := newInitServer(cmd.Process.Pid)
+	go srv.listen(container.controlSocketPath())
+
+	// Talk to the server without checking who it is
+	control := func(req *controlRequest) (*controlResponse, error) {
+		conn, err := net.Dial("unix", container.controlSocketPath())
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		return sendControl(conn, req)
+	}
+	setTimeout(t, "Waiting for the control socket timed out", 2*time.Second, func() {
+		for {
+			if _, err := control(&controlRequest{Action: "ping"}); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	// The test isn't the PID 1 of a container: the daemon doesn't trust it
+	if container.hasControl() {
+		t.Fatal("hasControl should be false when the socket isn't served by the init of the container")
+	}
+
+	resp, err := control(&controlRequest{Action: "ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Pid != cmd.Process.Pid || !resp.Running {
+		t.Fatalf("Unexpected response to ping: %#v", resp)
+	}
+
+	if _, err := control(&controlRequest{Action: "bogus"}); err == nil {
+		t.Fatal("An unknown action should fail")
+	}
+
+	// Wait for the exit status while the program is being signaled
+	waitResp := make(chan *controlResponse)
+	go func() {
+		resp, err := control(&controlRequest{Action: "wait"})
+		if err != nil {
+			t.Error(err)
+		}
+		waitResp <- resp
+	}()
+
+	if _, err := control(&controlRequest{Action: "signal", Signal: int(syscall.SIGTERM)}); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Wait()
+	status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !status.Signaled() || status.Signal() != syscall.SIGTERM {
+		t.Fatalf("The program should have been killed by SIGTERM: %s", cmd.ProcessState)
+	}
+	srv.finish(128+int(syscall.SIGTERM), int(syscall.SIGTERM))
+
+	setTimeout(t, "Waiting for the exit status timed out", 2*time.Second, func() {
+		resp := <-waitResp
+		if resp == nil {
+			return
+		}
+		if resp.Running || resp.ExitCode != 128+int(syscall.SIGTERM) || resp.Signal != int(syscall.SIGTERM) {
+			t.Errorf("Unexpected response to wait: %#v", resp)
+		}
+	})
+}
+
+func TestIsNamespaceInit(t *testing.T) {
+	// The daemon isn't the PID 1 of a namespace below its own
+	isInit, err := isNamespaceInit(os.Getpid())
+	if err != nil && err != errNoNSpid {
+		t.Fatal(err)
+	}
+	if isInit {
+		t.Fatalf("The test process shouldn't be the init of a namespace")
+	}
+	// A process which exited is no init, whatever the kernel
+	if isInit, err := isNamespaceInit(-1); isInit || err != nil {
+		t.Fatalf("Expected a missing process not to be an init, got %v, %v", isInit, err)
+	}
+}
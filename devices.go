@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// deviceMapping is a host device given to a container with -device
+type deviceMapping struct {
+	PathOnHost      string
+	PathInContainer string
+	Permissions     string // Any combination of r (read), w (write) and m (mknod)
+	NodePath        string // Node created for the container, bind mounted at PathInContainer
+
+	mode uint32
+	rdev uint64
+}
+
+// parseDevice parses a device specification of the form
+// host[:container[:permissions]]. The device keeps its host path and
+// gets every permission unless told otherwise.
+func parseDevice(spec string) (*deviceMapping, error) {
+	device := &deviceMapping{Permissions: "rwm"}
+	arr := strings.Split(spec, ":")
+	switch len(arr) {
+	case 3:
+		device.Permissions = arr[2]
+		fallthrough
+	case 2:
+		device.PathInContainer = arr[1]
+		fallthrough
+	case 1:
+		device.PathOnHost = arr[0]
+	default:
+		return nil, fmt.Errorf("Invalid device specification: %s", spec)
+	}
+	if device.PathInContainer == "" {
+		device.PathInContainer = device.PathOnHost
+	}
+	if !path.IsAbs(device.PathOnHost) || !path.IsAbs(device.PathInContainer) {
+		return nil, fmt.Errorf("Invalid device specification: %s. The paths must be absolute.", spec)
+	}
+	if device.Permissions == "" || strings.Trim(device.Permissions, "rwm") != "" {
+		return nil, fmt.Errorf("Invalid device permissions: %s", device.Permissions)
+	}
+	return device, nil
+}
+
+// lookup reads the type and the numbers of the device on the host
+func (device *deviceMapping) lookup() error {
+	stat := &syscall.Stat_t{}
+	if err := syscall.Stat(device.PathOnHost, stat); err != nil {
+		return fmt.Errorf("No such device: %s", device.PathOnHost)
+	}
+	mode := uint32(stat.Mode)
+	if mode&syscall.S_IFMT != syscall.S_IFCHR && mode&syscall.S_IFMT != syscall.S_IFBLK {
+		return fmt.Errorf("%s is not a device", device.PathOnHost)
+	}
+	device.mode = mode
+	device.rdev = uint64(stat.Rdev)
+	return nil
+}
+
+// cgroupRule returns the device whitelist entry, in the format of devices.allow
+func (device *deviceMapping) cgroupRule() string {
+	kind := "c"
+	if device.mode&syscall.S_IFMT == syscall.S_IFBLK {
+		kind = "b"
+	}
+	major := (device.rdev >> 8) & 0xfff
+	minor := (device.rdev & 0xff) | ((device.rdev >> 12) & 0xfff00)
+	return fmt.Sprintf("%s %d:%d %s", kind, major, minor, device.Permissions)
+}
+
+func (container *Container) devicesPath() string {
+	return path.Join(container.root, "devices")
+}
+
+// setupDevices looks up the devices given to the container and creates their
+// nodes next to its root filesystem, to be bind mounted into it: they never
+// end up in its changes. Their access is granted by cgroupSettings.
+func (container *Container) setupDevices(hostConfig *HostConfig) error {
+	container.devices = nil
+	if err := os.RemoveAll(container.devicesPath()); err != nil {
+		return err
+	}
+	if len(hostConfig.Devices) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(container.devicesPath(), 0755); err != nil {
+		return err
+	}
+	rootfs := container.RootfsPath()
+	for i, spec := range hostConfig.Devices {
+		device, err := parseDevice(spec)
+		if err != nil {
+			return err
+		}
+		if err := device.lookup(); err != nil {
+			return err
+		}
+		device.NodePath = path.Join(container.devicesPath(), strconv.Itoa(i))
+		if err := syscall.Mknod(device.NodePath, device.mode, int(device.rdev)); err != nil {
+			return fmt.Errorf("Unable to create the device %s: %s", device.PathInContainer, err)
+		}
+
+		// The mountpoint can be any kind of file but a directory, it is
+		// resolved without leaving the rootfs
+		dst, err := utils.FollowSymlinkInScope(path.Join(rootfs, device.PathInContainer), rootfs)
+		if err != nil {
+			return err
+		}
+		if stat, err := os.Stat(dst); err == nil && stat.IsDir() {
+			return fmt.Errorf("Impossible to mount the device %s on a directory", device.PathInContainer)
+		} else if os.IsNotExist(err) {
+			if err := container.createMountpoint(dst, false); err != nil {
+				return err
+			}
+		}
+		container.devices = append(container.devices, device)
+	}
+	return nil
+}
+
+// containerDevices returns the devices given to the container, for the lxc
+// template
+func containerDevices(container *Container) []*deviceMapping {
+	return container.devices
+}
@@ -0,0 +1,110 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+func TestParseDevice(t *testing.T) {
+	device, err := parseDevice("/dev/fuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.PathOnHost != "/dev/fuse" || device.PathInContainer != "/dev/fuse" || device.Permissions != "rwm" {
+		t.Fatalf("Unexpected device: %v", device)
+	}
+
+	device, err = parseDevice("/dev/ttyUSB0:/dev/ttyS0:rw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.PathOnHost != "/dev/ttyUSB0" || device.PathInContainer != "/dev/ttyS0" || device.Permissions != "rw" {
+		t.Fatalf("Unexpected device: %v", device)
+	}
+
+	for _, spec := range []string{"", "dev/fuse", "/dev/fuse:fuse", "/dev/fuse:/dev/fuse:rwx", "/dev/fuse:/dev/fuse:", "/a:/b:r:w"} {
+		if _, err := parseDevice(spec); err == nil {
+			t.Errorf("%s should be refused", spec)
+		}
+	}
+}
+
+func TestDeviceCgroupRule(t *testing.T) {
+	device, err := parseDevice("/dev/null:/dev/null:rw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := device.lookup(); err != nil {
+		t.Fatal(err)
+	}
+	if rule := device.cgroupRule(); rule != "c 1:3 rw" {
+		t.Fatalf("Expected c 1:3 rw, got %s", rule)
+	}
+
+	device, err = parseDevice("/etc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := device.lookup(); err == nil {
+		t.Fatalf("Regular files should be refused")
+	}
+
+	container := &Container{Config: &Config{}, devices: []*deviceMapping{device}}
+	device.mode, device.rdev = syscall.S_IFCHR, 10<<8|229
+	found := false
+	for _, setting := range cgroupSettings(container) {
+		if setting.Key == "devices.allow" && setting.Value == "c 10:229 rwm" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("The device should be allowed in the cgroup")
+	}
+}
+
+func TestSetupDevices(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Creating device nodes needs root")
+	}
+	root, err := ioutil.TempDir("", "docker-test-devices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	container := &Container{root: root}
+	// The /dev of the image points to a directory of the host
+	host := path.Join(root, "host")
+	if err := os.MkdirAll(host, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(container.RootfsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(host, path.Join(container.RootfsPath(), "dev")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := container.setupDevices(&HostConfig{Devices: []string{"/dev/null:/dev/mynull"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(container.devices) != 1 {
+		t.Fatalf("Expected 1 device, got %v", container.devices)
+	}
+	if fi, err := os.Stat(container.devices[0].NodePath); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		t.Fatalf("The node of the device wasn't created: %v", err)
+	}
+	if files, _ := ioutil.ReadDir(host); len(files) != 0 {
+		t.Fatalf("The files of the host were touched: %v", files)
+	}
+	// The rootfs only gets a mountpoint
+	fi, err := os.Stat(path.Join(container.RootfsPath(), host, "mynull"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.Mode().IsRegular() {
+		t.Fatalf("The mountpoint of the device should be a regular file, got %s", fi.Mode())
+	}
+}
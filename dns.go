@@ -0,0 +1,148 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+)
+
+// Every container gets a resolv.conf and an /etc/hosts of its own, written in
+// its root directory when it starts and bind mounted read-only. Changes made
+// to the files of the host afterwards don't affect running containers.
+
+// parseExtraHost parses an /etc/hosts entry given as host:ip
+func parseExtraHost(spec string) (string, string, error) {
+	i := strings.Index(spec, ":")
+	if i <= 0 {
+		return "", "", fmt.Errorf("Invalid host entry: %s. It needs to be of the form host:ip.", spec)
+	}
+	host, ip := spec[:i], spec[i+1:]
+	if net.ParseIP(ip) == nil {
+		return "", "", fmt.Errorf("Invalid IP address in host entry: %s", spec)
+	}
+	return host, ip, nil
+}
+
+// buildResolvConf generates the resolv.conf of a container from the one of
+// the host. The nameservers, search domains and options of the host are used
+// unless they are given explicitly. Local nameservers are unreachable from
+// containers and are replaced by defaultDns.
+func buildResolvConf(hostResolvConf []byte, dns, search, options []string) []byte {
+	var hostDns, hostSearch, hostOptions []string
+	for _, line := range strings.Split(string(hostResolvConf), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			if !strings.HasPrefix(fields[1], "127.") {
+				hostDns = append(hostDns, fields[1])
+			}
+		case "search", "domain":
+			// The last search or domain line wins
+			hostSearch = fields[1:]
+		case "options":
+			hostOptions = append(hostOptions, fields[1:]...)
+		}
+	}
+	if len(dns) == 0 {
+		dns = hostDns
+		if len(dns) == 0 {
+			dns = defaultDns
+		}
+	}
+	if len(search) == 0 {
+		search = hostSearch
+	}
+	if len(options) == 0 {
+		options = hostOptions
+	}
+
+	var buf bytes.Buffer
+	for _, ns := range dns {
+		fmt.Fprintf(&buf, "nameserver %s\n", ns)
+	}
+	if len(search) > 0 {
+		fmt.Fprintf(&buf, "search %s\n", strings.Join(search, " "))
+	}
+	if len(options) > 0 {
+		fmt.Fprintf(&buf, "options %s\n", strings.Join(options, " "))
+	}
+	return buf.Bytes()
+}
+
+// buildHosts generates the /etc/hosts of a container
+func buildHosts(hostname, ip string, extraHosts []string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "127.0.0.1\tlocalhost\n")
+	fmt.Fprintf(&buf, "::1\tlocalhost ip6-localhost ip6-loopback\n")
+	if ip != "" {
+		fmt.Fprintf(&buf, "%s\t%s\n", ip, hostname)
+	} else {
+		fmt.Fprintf(&buf, "127.0.1.1\t%s\n", hostname)
+	}
+	for _, spec := range extraHosts {
+		host, ip, err := parseExtraHost(spec)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s\t%s\n", ip, host)
+	}
+	return buf.Bytes(), nil
+}
+
+// setupHostFiles writes the resolv.conf and the /etc/hosts of the container,
+// and creates their mountpoints in its root filesystem
+func (container *Container) setupHostFiles(hostConfig *HostConfig) error {
+	dns := container.Config.Dns
+	if len(dns) == 0 {
+		dns = container.runtime.Dns
+	}
+	hostResolvConf, err := ioutil.ReadFile("/etc/resolv.conf")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	container.ResolvConfPath = path.Join(container.root, "resolv.conf")
+	resolvConf := buildResolvConf(hostResolvConf, dns, container.Config.DnsSearch, container.Config.DnsOptions)
+	if err := ioutil.WriteFile(container.ResolvConfPath, resolvConf, 0644); err != nil {
+		return err
+	}
+
+	hostname := container.Config.Hostname
+	if hostname == "" {
+		hostname = container.ShortID()
+	}
+	hosts, err := buildHosts(hostname, container.NetworkSettings.IPAddress, hostConfig.ExtraHosts)
+	if err != nil {
+		return err
+	}
+	container.HostsPath = path.Join(container.root, "hosts")
+	if err := ioutil.WriteFile(container.HostsPath, hosts, 0644); err != nil {
+		return err
+	}
+
+	// The mountpoints must be files. The directories of the image can be
+	// symlinks, which are resolved without leaving the rootfs.
+	rootfs := container.RootfsPath()
+	for _, file := range []string{"/etc/resolv.conf", "/etc/hosts"} {
+		dir, err := utils.FollowSymlinkInScope(path.Join(rootfs, path.Dir(file)), rootfs)
+		if err != nil {
+			return err
+		}
+		target := path.Join(dir, path.Base(file))
+		if stat, err := os.Lstat(target); err == nil && stat.Mode().IsRegular() {
+			continue
+		}
+		os.RemoveAll(target)
+		if err := container.createMountpoint(target, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBuildResolvConf(t *testing.T) {
+	host := []byte("# generated\nnameserver 127.0.1.1\nnameserver 10.0.0.1\nsearch example.com\noptions rotate\n")
+
+	// Local nameservers of the host are skipped
+	if resolvConf := string(buildResolvConf(host, nil, nil, nil)); resolvConf != "nameserver 10.0.0.1\nsearch example.com\noptions rotate\n" {
+		t.Fatalf("Unexpected resolv.conf: %q", resolvConf)
+	}
+
+	resolvConf := string(buildResolvConf(host, []string{"1.2.3.4"}, []string{"a.com", "b.com"}, []string{"ndots:2"}))
+	if resolvConf != "nameserver 1.2.3.4\nsearch a.com b.com\noptions ndots:2\n" {
+		t.Fatalf("Unexpected resolv.conf: %q", resolvConf)
+	}
+
+	// Without any usable nameserver, fall back on the default ones
+	if resolvConf := string(buildResolvConf([]byte("nameserver 127.0.0.1\n"), nil, nil, nil)); resolvConf != "nameserver 8.8.8.8\nnameserver 8.8.4.4\n" {
+		t.Fatalf("Unexpected resolv.conf: %q", resolvConf)
+	}
+}
+
+func TestBuildHosts(t *testing.T) {
+	hosts, err := buildHosts("web", "172.16.42.2", []string{"db:10.0.0.2", "v6:fe80::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost ip6-loopback\n172.16.42.2\tweb\n10.0.0.2\tdb\nfe80::1\tv6\n"
+	if string(hosts) != expected {
+		t.Fatalf("Expected %q, got %q", expected, hosts)
+	}
+
+	for _, spec := range []string{"db", ":10.0.0.2", "db:nowhere"} {
+		if _, err := buildHosts("web", "", []string{spec}); err == nil {
+			t.Errorf("%s should be refused", spec)
+		}
+	}
+}
+
+func TestSetupHostFilesSymlink(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-test-host-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	container := &Container{
+		root:            root,
+		Config:          &Config{Hostname: "web", Dns: []string{"1.2.3.4"}},
+		NetworkSettings: &NetworkSettings{},
+	}
+	// The /etc of the image points to a directory of the host
+	host := path.Join(root, "host")
+	if err := os.MkdirAll(host, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(container.RootfsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(host, path.Join(container.RootfsPath(), "etc")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := container.setupHostFiles(&HostConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := ioutil.ReadDir(host); len(files) != 0 {
+		t.Fatalf("The files of the host were touched: %v", files)
+	}
+	for _, file := range []string{"resolv.conf", "hosts"} {
+		if _, err := os.Stat(path.Join(container.RootfsPath(), host, file)); err != nil {
+			t.Fatalf("The mountpoint of %s wasn't created within the rootfs: %s", file, err)
+		}
+	}
+}
@@ -19,7 +19,7 @@ var (
 )
 
 func main() {
-	if utils.SelfPath() == "/sbin/init" {
+	if docker.IsNativeInit() || utils.SelfPath() == "/sbin/init" {
 		// Running in init mode
 		docker.SysInit()
 		return
@@ -28,11 +28,15 @@ func main() {
 	flDaemon := flag.Bool("d", false, "Daemon mode")
 	flDebug := flag.Bool("D", false, "Debug mode")
 	flAutoRestart := flag.Bool("r", false, "Restart previously running containers")
-	bridgeName := flag.String("b", "", "Attach containers to a pre-existing network bridge")
+	bridgeName := flag.String("b", "", "Attach containers to a pre-existing network bridge; use 'none' to disable container networking")
 	pidfile := flag.String("p", "/var/run/docker.pid", "File containing process PID")
 	flGraphPath := flag.String("g", "/var/lib/docker", "Path to graph storage base dir.")
 	flEnableCors := flag.Bool("api-enable-cors", false, "Enable CORS requests in the remote api.")
 	flDns := flag.String("dns", "", "Set custom dns servers")
+	flExecDriver := flag.String("e", docker.DefaultExecDriver, "Force the docker runtime to use a specific exec driver (lxc or native)")
+	var flDefaultUlimits docker.ListOpts
+	flag.Var(&flDefaultUlimits, "default-ulimit", "Set a default resource limit for containers (e.g. -default-ulimit nofile=1024:4096)")
+	flUsernsRemap := flag.String("userns-remap", "", "Run containers in user namespaces, mapping their root to the subordinate ids of 'default' (the dockremap user) or user[:group]")
 	flHosts := docker.ListOpts{fmt.Sprintf("tcp://%s:%d", docker.DEFAULTHTTPHOST, docker.DEFAULTHTTPPORT)}
 	flag.Var(&flHosts, "H", "tcp://host:port to bind/connect to or unix://path/to/socket to use")
 	flag.Parse()
@@ -48,6 +52,15 @@ func main() {
 	} else {
 		docker.NetworkBridgeIface = docker.DefaultNetworkBridge
 	}
+	docker.ExecDriverName = *flExecDriver
+	docker.UsernsRemap = *flUsernsRemap
+	for _, spec := range flDefaultUlimits {
+		u, err := docker.ParseUlimit(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		docker.DefaultUlimits = append(docker.DefaultUlimits, u)
+	}
 	if *flDebug {
 		os.Setenv("DEBUG", "1")
 	}
@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+const DefaultExecDriver = "lxc"
+
+// ExecDriverName selects the execution driver of new runtimes
+var ExecDriverName string
+
+func newExecDriver(name string) (ExecDriver, error) {
+	switch name {
+	case "lxc":
+		return newLxcDriver(), nil
+	case "native":
+		return newNativeDriver()
+	case "fake":
+		return newFakeDriver(), nil
+	}
+	return nil, fmt.Errorf("Unknown execution driver: %s", name)
+}
+
+// An ExecDriver runs the processes of containers. Container takes care of
+// everything which doesn't depend on how the process is isolated: the
+// filesystem, the network interface, the arguments of docker-init and the
+// standard streams.
+type ExecDriver interface {
+	// Name identifies the driver, eg. in `docker info`
+	Name() string
+
+	// Start runs cmd inside the container. cmd is a command line of
+	// docker-init as /sbin/init, with its standard streams already set up.
+	// The driver may rewrite its path and arguments before starting it, but
+	// cmd.Wait must return when the container exits.
+	Start(container *Container, cmd *exec.Cmd) error
+
+	// Kill sends sig to the process of a running container
+	Kill(container *Container, sig int) error
+
+	// Wait blocks until a container which was not started by this daemon,
+	// eg. before a restart, exits. It returns the exit code of the container
+	// or -1 if it can't be known.
+	Wait(container *Container) (int, error)
+
+	// Info reports the state of the container as seen by the driver
+	Info(container *Container) (*ExecInfo, error)
+
+	// Pause freezes all the processes of a running container, Unpause
+	// resumes them
+	Pause(container *Container) error
+	Unpause(container *Container) error
+
+	// Exec returns a command running args inside a running container
+	Exec(container *Container, args []string) (*exec.Cmd, error)
+
+	// Cleanup releases what the driver set up for a container which exited
+	Cleanup(container *Container) error
+}
+
+type ExecInfo struct {
+	Running bool
+	Pid     int
+}
@@ -0,0 +1,130 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// fakeDriver runs the program of a container as a plain child process of the
+// daemon, without any isolation and without docker-init. It is chrooted into
+// the root filesystem of the container when running as root, and only starts
+// there otherwise. It is meant to run the tests on hosts without lxc, AUFS or
+// root privileges, together with CopyMount and a disabled network bridge.
+type fakeDriver struct{}
+
+func newFakeDriver() *fakeDriver {
+	return &fakeDriver{}
+}
+
+func (d *fakeDriver) Name() string {
+	return "fake"
+}
+
+func (d *fakeDriver) Start(container *Container, cmd *exec.Cmd) error {
+	rootfs := container.RootfsPath()
+	chroot := syscall.Geteuid() == 0
+
+	env := []string{
+		"HOME=/",
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	}
+	if container.Config.Tty {
+		env = append(env, "TERM=xterm")
+	}
+	env = append(env, container.Config.Env...)
+
+	program, err := d.lookPath(container.Path, rootfs, chroot)
+	if err != nil {
+		return err
+	}
+	cmd.Path = program
+	cmd.Args = append([]string{container.Path}, container.Args...)
+	cmd.Env = env
+	if chroot {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Chroot = rootfs
+		cmd.Dir = "/"
+	} else {
+		cmd.Dir = rootfs
+	}
+	if container.Config.WorkingDir != "" {
+		cmd.Dir = path.Join(cmd.Dir, container.Config.WorkingDir)
+		if err := os.MkdirAll(path.Join(rootfs, container.Config.WorkingDir), 0755); err != nil {
+			return err
+		}
+	}
+	return cmd.Start()
+}
+
+// lookPath resolves name in the root filesystem of the container when
+// chrooted, or on the host otherwise
+func (d *fakeDriver) lookPath(name, rootfs string, chroot bool) (string, error) {
+	if !chroot {
+		return exec.LookPath(name)
+	}
+	if strings.Contains(name, "/") {
+		return name, nil
+	}
+	for _, dir := range []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"} {
+		if stat, err := os.Stat(path.Join(rootfs, dir, name)); err == nil && !stat.IsDir() {
+			return path.Join(dir, name), nil
+		}
+	}
+	return "", fmt.Errorf("Unable to locate %s", name)
+}
+
+func (d *fakeDriver) Kill(container *Container, sig int) error {
+	if container.State.Pid == 0 {
+		return fmt.Errorf("No process for container %s", container.ID)
+	}
+	return syscall.Kill(container.State.Pid, syscall.Signal(sig))
+}
+
+func (d *fakeDriver) Wait(container *Container) (int, error) {
+	for {
+		info, err := d.Info(container)
+		if err != nil {
+			return -1, err
+		}
+		if !info.Running {
+			return -1, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (d *fakeDriver) Info(container *Container) (*ExecInfo, error) {
+	pid := container.State.Pid
+	if pid == 0 || syscall.Kill(pid, 0) != nil {
+		return &ExecInfo{}, nil
+	}
+	return &ExecInfo{Running: true, Pid: pid}, nil
+}
+
+func (d *fakeDriver) Pause(container *Container) error {
+	return d.Kill(container, int(syscall.SIGSTOP))
+}
+
+func (d *fakeDriver) Unpause(container *Container) error {
+	return d.Kill(container, int(syscall.SIGCONT))
+}
+
+func (d *fakeDriver) Exec(container *Container, args []string) (*exec.Cmd, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("No command specified")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = container.RootfsPath()
+	return cmd, nil
+}
+
+func (d *fakeDriver) Cleanup(container *Container) error {
+	return nil
+}
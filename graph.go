@@ -22,6 +22,7 @@ type Graph struct {
 	checksumLock map[string]*sync.Mutex
 	lockSumFile  *sync.Mutex
 	lockSumMap   *sync.Mutex
+	idMappings   *idMappings // Set if the layers are shifted for user namespaces
 }
 
 // NewGraph instantiates a new graph at the given root path in the filesystem.
@@ -148,6 +149,11 @@ func (graph *Graph) Register(layerData Archive, store bool, img *Image) error {
 	if err := StoreImage(img, layerData, tmp, store); err != nil {
 		return err
 	}
+	if graph.idMappings != nil {
+		if err := graph.idMappings.shiftTree(layerPath(tmp)); err != nil {
+			return err
+		}
+	}
 	// Commit
 	if err := os.Rename(tmp, graph.imageRoot(img.ID)); err != nil {
 		return err
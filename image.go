@@ -167,7 +167,7 @@ func (image *Image) TarLayer(compression Compression) (Archive, error) {
 	if err != nil {
 		return nil, err
 	}
-	return Tar(layerPath, compression)
+	return image.graph.idMappings.Tar(layerPath, compression)
 }
 
 func (image *Image) Mount(root, rw string) error {
@@ -187,6 +187,9 @@ func (image *Image) Mount(root, rw string) error {
 	if err := os.Mkdir(rw, 0755); err != nil && !os.IsExist(err) {
 		return err
 	}
+	if CopyMount {
+		return MountCopy(layers, root)
+	}
 	if err := MountAUFS(layers, rw, root); err != nil {
 		return err
 	}
@@ -338,7 +341,7 @@ func (img *Image) Checksum() (string, error) {
 
 	if file, err := os.Open(layerArchivePath(root)); err != nil {
 		if os.IsNotExist(err) {
-			layerData, err = Tar(layer, Xz)
+			layerData, err = img.graph.idMappings.Tar(layer, Xz)
 			if err != nil {
 				return "", err
 			}
@@ -0,0 +1,155 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lxcDriver runs containers with the lxc userspace tools
+type lxcDriver struct{}
+
+func newLxcDriver() *lxcDriver {
+	return &lxcDriver{}
+}
+
+func (d *lxcDriver) Name() string {
+	return "lxc"
+}
+
+func (d *lxcDriver) Start(container *Container, cmd *exec.Cmd) error {
+	if err := d.generateConfig(container); err != nil {
+		return err
+	}
+	lxcStart, err := exec.LookPath("lxc-start")
+	if err != nil {
+		return err
+	}
+	cmd.Path = lxcStart
+	cmd.Args = append([]string{
+		"lxc-start",
+		"-n", container.ID,
+		"-f", d.configPath(container),
+		"--",
+	}, cmd.Args...)
+	return cmd.Start()
+}
+
+// Kill signals the PID 1 of the container directly, falling back on lxc-kill
+// when its cgroup can't be found
+func (d *lxcDriver) Kill(container *Container, sig int) error {
+	if pid, err := container.initPid(); err == nil {
+		return syscall.Kill(pid, syscall.Signal(sig))
+	}
+	if output, err := exec.Command("lxc-kill", "-n", container.ID, strconv.Itoa(sig)).CombinedOutput(); err != nil {
+		return fmt.Errorf("lxc-kill failed: %s (%s)", output, err)
+	}
+	return nil
+}
+
+// lxc doesn't know the exit code of containers it didn't start itself
+func (d *lxcDriver) Wait(container *Container) (int, error) {
+	for {
+		info, err := d.Info(container)
+		if err != nil {
+			return -1, err
+		}
+		if !info.Running {
+			return -1, nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// Info looks for the PID 1 of the container in its cgroup, which lxc removes
+// when the container exits. It falls back on lxc-info when the cgroup can't
+// be found, e.g. with a layout of another version of lxc, or when the kernel
+// can't tell which process is the PID 1.
+func (d *lxcDriver) Info(container *Container) (*ExecInfo, error) {
+	if _, err := container.cgroupPath("freezer"); err == nil {
+		pid, err := container.initPid()
+		if err == nil {
+			return &ExecInfo{Running: true, Pid: pid}, nil
+		}
+		if err != errNoNSpid {
+			return &ExecInfo{}, nil
+		}
+	}
+	output, err := exec.Command("lxc-info", "-n", container.ID).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("lxc-info failed: %s (%s)", output, err)
+	}
+	info := &ExecInfo{
+		Running: strings.Contains(string(output), "RUNNING"),
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if strings.ToLower(strings.TrimSpace(parts[0])) == "pid" {
+			info.Pid, _ = strconv.Atoi(strings.TrimSpace(parts[1]))
+		}
+	}
+	return info, nil
+}
+
+func (d *lxcDriver) Pause(container *Container) error {
+	if output, err := exec.Command("lxc-freeze", "-n", container.ID).CombinedOutput(); err != nil {
+		return fmt.Errorf("lxc-freeze failed: %s (%s)", output, err)
+	}
+	return nil
+}
+
+func (d *lxcDriver) Unpause(container *Container) error {
+	if output, err := exec.Command("lxc-unfreeze", "-n", container.ID).CombinedOutput(); err != nil {
+		return fmt.Errorf("lxc-unfreeze failed: %s (%s)", output, err)
+	}
+	return nil
+}
+
+func (d *lxcDriver) Exec(container *Container, args []string) (*exec.Cmd, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("No command specified")
+	}
+	return exec.Command("lxc-attach", append([]string{"-n", container.ID, "--"}, args...)...), nil
+}
+
+// lxc-start cleans up after itself
+func (d *lxcDriver) Cleanup(container *Container) error {
+	return nil
+}
+
+func (d *lxcDriver) configPath(container *Container) string {
+	return path.Join(container.root, "config.lxc")
+}
+
+func (d *lxcDriver) generateConfig(container *Container) error {
+	if seccompPath := lxcSeccompPath(container); seccompPath != "" {
+		policy, err := container.seccomp.lxcPolicy()
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(seccompPath, policy, 0644); err != nil {
+			return err
+		}
+	}
+	fo, err := os.Create(d.configPath(container))
+	if err != nil {
+		return err
+	}
+	defer fo.Close()
+	if err := LxcTemplateCompiled.Execute(fo, container); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package docker
 
 import (
+	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"path"
+	"strings"
 	"text/template"
 )
 
@@ -11,15 +15,34 @@ lxc.utsname = {{.Config.Hostname}}
 {{else}}
 lxc.utsname = {{.Id}}
 {{end}}
-#lxc.aa_profile = unconfined
+
+# AppArmor profile, lxc uses its default one if none is given
+{{if .AppArmorProfile}}
+lxc.aa_profile = {{.AppArmorProfile}}
+{{end}}
+
+# SELinux label, for the binds relabeled as private
+{{if .ProcessLabel}}
+lxc.se_context = {{.ProcessLabel}}
+{{end}}
+
+# user namespace
+{{range lxcIDMaps .}}
+lxc.id_map = {{.}}
+{{end}}
 
 # network configuration
+{{if .NetworkSettings.IPAddress}}
 lxc.network.type = veth
 lxc.network.flags = up
 lxc.network.link = {{.NetworkSettings.Bridge}}
 lxc.network.name = eth0
 lxc.network.mtu = 1500
 lxc.network.ipv4 = {{.NetworkSettings.IPAddress}}/{{.NetworkSettings.IPPrefixLen}}
+{{else}}
+# networking is disabled, only set up the loopback interface
+lxc.network.type = empty
+{{end}}
 
 # root filesystem
 {{$ROOTFS := .RootfsPath}}
@@ -35,36 +58,12 @@ lxc.console = none
 # no controlling tty at all
 lxc.tty = 1
 
-# no implicit access to devices
-lxc.cgroup.devices.deny = a
-
-# /dev/null and zero
-lxc.cgroup.devices.allow = c 1:3 rwm
-lxc.cgroup.devices.allow = c 1:5 rwm
-
-# consoles
-lxc.cgroup.devices.allow = c 5:1 rwm
-lxc.cgroup.devices.allow = c 5:0 rwm
-lxc.cgroup.devices.allow = c 4:0 rwm
-lxc.cgroup.devices.allow = c 4:1 rwm
-
-# /dev/urandom,/dev/random
-lxc.cgroup.devices.allow = c 1:9 rwm
-lxc.cgroup.devices.allow = c 1:8 rwm
-
-# /dev/pts/* - pts namespaces are "coming soon"
-lxc.cgroup.devices.allow = c 136:* rwm
-lxc.cgroup.devices.allow = c 5:2 rwm
-
-# tuntap
-lxc.cgroup.devices.allow = c 10:200 rwm
-
-# fuse
-#lxc.cgroup.devices.allow = c 10:229 rwm
-
-# rtc
-#lxc.cgroup.devices.allow = c 254:0 rwm
-
+# cgroups: devices whitelist and resource limits
+{{$cgroup := lxcCgroupKey}}
+{{range lxcCgroupSettings .}}
+lxc.{{$cgroup}}.{{.Key}} = {{.Value}}
+{{end}}
+# (other host devices, e.g. fuse or rtc, can be given with -device)
 
 # standard mount point
 #  WARNING: procfs is a known attack vector and should probably be disabled
@@ -74,19 +73,31 @@ lxc.mount.entry = proc {{$ROOTFS}}/proc proc nosuid,nodev,noexec 0 0
 #           if your userspace allows it. eg. see http://bit.ly/T9CkqJ
 lxc.mount.entry = sysfs {{$ROOTFS}}/sys sysfs nosuid,nodev,noexec 0 0
 lxc.mount.entry = devpts {{$ROOTFS}}/dev/pts devpts newinstance,ptmxmode=0666,nosuid,noexec 0 0
-#lxc.mount.entry = varrun {{$ROOTFS}}/var/run tmpfs mode=755,size=4096k,nosuid,nodev,noexec 0 0
-#lxc.mount.entry = varlock {{$ROOTFS}}/var/lock tmpfs size=1024k,nosuid,nodev,noexec 0 0
-#lxc.mount.entry = shm {{$ROOTFS}}/dev/shm tmpfs size=65536k,nosuid,nodev,noexec 0 0
+
+# /dev/shm and the tmpfs filesystems given with -tmpfs
+{{range tmpfsMounts .}}
+lxc.mount.entry = tmpfs {{$ROOTFS}}{{.Target}} tmpfs {{.Options}} 0 0
+{{end}}
 
 # Inject docker-init
 lxc.mount.entry = {{.SysInitPath}} {{$ROOTFS}}/sbin/init none bind,ro 0 0
 
-# In order to get a working DNS environment, mount bind (ro) the host's /etc/resolv.conf into the container
+# Devices given with -device
+{{range containerDevices .}}
+lxc.mount.entry = {{.NodePath}} {{$ROOTFS}}{{.PathInContainer}} none bind 0 0
+{{end}}
+
+# Control socket of docker-init
+lxc.mount.entry = {{.InitDirPath}} {{$ROOTFS}}/.dockerinit none bind,rw 0 0
+
+# In order to get a working DNS environment, mount bind (ro) the resolv.conf and hosts files of the container
 lxc.mount.entry = {{.ResolvConfPath}} {{$ROOTFS}}/etc/resolv.conf none bind,ro 0 0
+lxc.mount.entry = {{.HostsPath}} {{$ROOTFS}}/etc/hosts none bind,ro 0 0
 {{if .Volumes}}
 {{ $rw := .VolumesRW }}
+{{ $propagation := .VolumesPropagation }}
 {{range $virtualPath, $realPath := .Volumes}}
-lxc.mount.entry = {{$realPath}} {{$ROOTFS}}/{{$virtualPath}} none bind,{{ if index $rw $virtualPath }}rw{{else}}ro{{end}} 0 0
+lxc.mount.entry = {{$realPath}} {{$ROOTFS}}/{{$virtualPath}} none bind,{{ if index $rw $virtualPath }}rw{{else}}ro{{end}}{{with index $propagation $virtualPath}},{{.}}{{end}} 0 0
 {{end}}
 {{end}}
 
@@ -94,18 +105,13 @@ lxc.mount.entry = {{$realPath}} {{$ROOTFS}}/{{$virtualPath}} none bind,{{ if ind
 #  (Note: 'lxc.cap.keep' is coming soon and should replace this under the
 #         security principle 'deny all unless explicitly permitted', see
 #         http://sourceforge.net/mailarchive/message.php?msg_id=31054627 )
-lxc.cap.drop = audit_control audit_write mac_admin mac_override mknod setfcap setpcap sys_admin sys_boot sys_module sys_nice sys_pacct sys_rawio sys_resource sys_time sys_tty_config
-
-# limits
-{{if .Config.Memory}}
-lxc.cgroup.memory.limit_in_bytes = {{.Config.Memory}}
-lxc.cgroup.memory.soft_limit_in_bytes = {{.Config.Memory}}
-{{with $memSwap := getMemorySwap .Config}}
-lxc.cgroup.memory.memsw.limit_in_bytes = {{$memSwap}}
+{{with droppedCapabilities .}}
+lxc.cap.drop = {{join . " "}}
 {{end}}
-{{end}}
-{{if .Config.CpuShares}}
-lxc.cgroup.cpu.shares = {{.Config.CpuShares}}
+
+# filter system calls
+{{with lxcSeccompPath .}}
+lxc.seccomp = {{.}}
 {{end}}
 `
 
@@ -120,10 +126,68 @@ func getMemorySwap(config *Config) int64 {
 	return config.Memory * 2
 }
 
+// lxcCgroupSettings returns the cgroup settings of the container, translated
+// for the unified hierarchy if the host uses it
+func lxcCgroupSettings(container *Container) []cgroupSetting {
+	if utils.IsCgroupUnified() {
+		settings := cgroupSettings(container)
+		// lxc >= 4 turns the lxc.cgroup2.devices.* keys into an eBPF program
+		var unified []cgroupSetting
+		for _, setting := range settings {
+			if setting.Subsystem == "devices" {
+				unified = append(unified, setting)
+			}
+		}
+		return append(unified, toUnified(settings)...)
+	}
+	return cgroupSettings(container)
+}
+
+// lxcCgroupKey returns the prefix of the cgroup keys of the lxc config
+func lxcCgroupKey() string {
+	if utils.IsCgroupUnified() {
+		return "cgroup2"
+	}
+	return "cgroup"
+}
+
+// lxcSeccompPath returns the path of the seccomp policy of the container,
+// if it has a seccomp profile
+func lxcSeccompPath(container *Container) string {
+	if container.seccomp == nil {
+		return ""
+	}
+	return path.Join(container.root, "seccomp.lxc")
+}
+
+// lxcIDMaps returns the uid and gid mappings of the container, in the format
+// of lxc.id_map
+func lxcIDMaps(container *Container) []string {
+	m := container.runtime.idMappings
+	if m == nil {
+		return nil
+	}
+	idMaps := []string{}
+	for _, u := range m.Uids {
+		idMaps = append(idMaps, fmt.Sprintf("u %d %d %d", u.ContainerID, u.HostID, u.Size))
+	}
+	for _, g := range m.Gids {
+		idMaps = append(idMaps, fmt.Sprintf("g %d %d %d", g.ContainerID, g.HostID, g.Size))
+	}
+	return idMaps
+}
+
 func init() {
 	var err error
 	funcMap := template.FuncMap{
-		"getMemorySwap": getMemorySwap,
+		"lxcCgroupSettings":   lxcCgroupSettings,
+		"lxcCgroupKey":        lxcCgroupKey,
+		"droppedCapabilities": droppedCapabilities,
+		"join":                strings.Join,
+		"tmpfsMounts":         tmpfsMounts,
+		"containerDevices":    containerDevices,
+		"lxcSeccompPath":      lxcSeccompPath,
+		"lxcIDMaps":           lxcIDMaps,
 	}
 	LxcTemplateCompiled, err = template.New("lxc").Funcs(funcMap).Parse(LxcTemplate)
 	if err != nil {
@@ -3,14 +3,149 @@ package docker
 import (
 	"fmt"
 	"github.com/dotcloud/docker/utils"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 )
 
+// When CopyMount is true, the layers of images are copied into the root
+// directory of containers instead of being stacked with AUFS. This works
+// without AUFS nor root privileges, eg. to run the tests, but the changes
+// made in a container are not recorded in its rw layer: they are lost on
+// unmount and are not visible to diff, export or commit.
+var CopyMount bool
+
+// MountCopy copies the layers ro, topmost first, into target
+func MountCopy(ro []string, target string) error {
+	for i := len(ro) - 1; i >= 0; i-- {
+		if err := copyLayer(ro[i], target); err != nil {
+			removeCopy(target)
+			return err
+		}
+	}
+	return nil
+}
+
+// copyLayer copies the content of the layer src over dst, applying its AUFS whiteouts.
+// Device nodes are skipped when they can't be created.
+func copyLayer(src, dst string) error {
+	// The permissions of directories are applied last, in case they are read-only
+	dirModes := make(map[string]os.FileMode)
+	err := filepath.Walk(src, func(srcPath string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, srcPath)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		// Skip AUFS metadata
+		if strings.HasPrefix(rel, ".wh..wh.") {
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		dstPath := filepath.Join(dst, rel)
+		if base := filepath.Base(rel); strings.HasPrefix(base, ".wh.") {
+			return os.RemoveAll(filepath.Join(filepath.Dir(dstPath), base[len(".wh."):]))
+		}
+
+		stat := f.Sys().(*syscall.Stat_t)
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+			if existing, err := os.Lstat(dstPath); err == nil && !existing.IsDir() {
+				if err := os.Remove(dstPath); err != nil {
+					return err
+				}
+			}
+			if err := os.MkdirAll(dstPath, 0755); err != nil {
+				return err
+			}
+			dirModes[dstPath] = f.Mode()
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(srcPath)
+			if err != nil {
+				return err
+			}
+			os.RemoveAll(dstPath)
+			if err := os.Symlink(link, dstPath); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			os.RemoveAll(dstPath)
+			if err := copyFile(srcPath, dstPath, mode.Perm()); err != nil {
+				return err
+			}
+		default:
+			os.RemoveAll(dstPath)
+			if err := syscall.Mknod(dstPath, uint32(stat.Mode), int(stat.Rdev)); err != nil {
+				utils.Debugf("Skipping %s: %s", rel, err)
+				return nil
+			}
+		}
+		// Ownership can only be kept with enough privileges
+		os.Lchown(dstPath, int(stat.Uid), int(stat.Gid))
+		if !f.IsDir() && f.Mode()&os.ModeSymlink == 0 {
+			os.Chmod(dstPath, f.Mode())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for dir, mode := range dirModes {
+		if err := os.Chmod(dir, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// removeCopy removes a directory created by MountCopy, including its read-only
+// directories when running without root privileges
+func removeCopy(target string) error {
+	filepath.Walk(target, func(path string, f os.FileInfo, err error) error {
+		if err == nil && f.IsDir() {
+			os.Chmod(path, 0755)
+		}
+		return nil
+	})
+	return os.RemoveAll(target)
+}
+
 func Unmount(target string) error {
+	// Remove the read-only bind mount of containers with ReadonlyRootfs first
+	if isReadonly(target) {
+		if err := syscall.Unmount(target, 0); err != nil {
+			return err
+		}
+	}
+	if CopyMount {
+		return removeCopy(target)
+	}
 	if err := exec.Command("auplink", target, "flush").Run(); err != nil {
 		utils.Debugf("[warning]: couldn't run auplink before unmount: %s", err)
 	}
@@ -35,7 +170,26 @@ func Unmount(target string) error {
 	return fmt.Errorf("Umount: Failed to umount %v", target)
 }
 
+// isReadonly returns true if the filesystem at target is mounted read-only
+func isReadonly(target string) bool {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(target, &stat); err != nil {
+		return false
+	}
+	// ST_RDONLY on Linux, MNT_RDONLY on Darwin
+	return uint64(stat.Flags)&1 != 0
+}
+
 func Mounted(mountpoint string) (bool, error) {
+	if CopyMount {
+		if _, err := os.Stat(mountpoint); err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
 	mntpoint, err := os.Stat(mountpoint)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -5,3 +5,11 @@ import "errors"
 func mount(source string, target string, fstype string, flags uintptr, data string) (err error) {
 	return errors.New("mount is not implemented on darwin")
 }
+
+func MountReadonly(target string) error {
+	return errors.New("read-only mounts are not implemented on darwin")
+}
+
+func checkMountPropagation(p, propagation string) error {
+	return errors.New("mount propagation is not implemented on darwin")
+}
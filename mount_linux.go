@@ -1,7 +1,81 @@
 package docker
 
-import "syscall"
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
 
 func mount(source string, target string, fstype string, flags uintptr, data string) (err error) {
 	return syscall.Mount(source, target, fstype, flags, data)
 }
+
+// MountReadonly makes the filesystem at target read-only by bind mounting it
+// over itself. The mounts made below target afterwards keep their own mode.
+func MountReadonly(target string) error {
+	if isReadonly(target) {
+		return nil
+	}
+	if err := mount(target, target, "none", syscall.MS_BIND, ""); err != nil {
+		return err
+	}
+	if err := mount(target, target, "none", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+		syscall.Unmount(target, 0)
+		return err
+	}
+	return nil
+}
+
+// checkMountPropagation makes sure that the mount point of `p` lets its binds
+// propagate mounts as `propagation` asks. The binds of a private mount point
+// stay private whatever they ask: shared binds need a shared mount point, and
+// slave binds a shared mount point or a slave one.
+func checkMountPropagation(p, propagation string) error {
+	p, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return err
+	}
+	output, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	var (
+		mountpoint    string
+		shared, slave bool
+	)
+	for _, line := range strings.Split(string(output), "\n") {
+		// id parent major:minor root mountpoint options [optional fields...] - type source options
+		fields := strings.Fields(line)
+		if len(fields) < 7 {
+			continue
+		}
+		mp := strings.Replace(fields[4], "\\040", " ", -1)
+		// Mounts stacked on the same mount point come later
+		if (p == mp || strings.HasPrefix(p, strings.TrimSuffix(mp, "/")+"/")) && len(mp) >= len(mountpoint) {
+			mountpoint, shared, slave = mp, false, false
+			for _, field := range fields[6:] {
+				if field == "-" {
+					break
+				}
+				shared = shared || strings.HasPrefix(field, "shared:")
+				slave = slave || strings.HasPrefix(field, "master:")
+			}
+		}
+	}
+	if mountpoint == "" {
+		return fmt.Errorf("Unable to find the mount point of %s", p)
+	}
+	switch propagation {
+	case "shared", "rshared":
+		if !shared {
+			return fmt.Errorf("Impossible to use the %s propagation for %s: its mount point %s is not shared. Make it shared with mount --make-shared %s.", propagation, p, mountpoint, mountpoint)
+		}
+	case "slave", "rslave":
+		if !shared && !slave {
+			return fmt.Errorf("Impossible to use the %s propagation for %s: its mount point %s is private. Make it shared with mount --make-shared %s.", propagation, p, mountpoint, mountpoint)
+		}
+	}
+	return nil
+}
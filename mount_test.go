@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMountCopy(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-test-mountcopy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	base, top, target := path.Join(tmp, "base"), path.Join(tmp, "top"), path.Join(tmp, "rootfs")
+	writeFile(path.Join(base, "etc/hostname"), "base", t)
+	writeFile(path.Join(base, "etc/removed"), "base", t)
+	writeFile(path.Join(base, "bin/sh"), "shell", t)
+	writeFile(path.Join(top, "etc/hostname"), "top", t)
+	writeFile(path.Join(top, "etc/.wh.removed"), "", t)
+	writeFile(path.Join(top, ".wh..wh.aufs"), "", t)
+	if err := os.Symlink("sh", path.Join(base, "bin/bash")); err != nil {
+		t.Fatal(err)
+	}
+	// Read-only directories don't prevent copying their content
+	if err := os.Chmod(path.Join(base, "bin"), 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(path.Join(base, "bin"), 0755)
+
+	if err := MountCopy([]string{top, base}, target); err != nil {
+		t.Fatal(err)
+	}
+	if content := readFile(path.Join(target, "etc/hostname"), t); content != "top" {
+		t.Errorf("Expected the top layer to win, got %s", content)
+	}
+	if content := readFile(path.Join(target, "bin/sh"), t); content != "shell" {
+		t.Errorf("Expected shell, got %s", content)
+	}
+	if link, err := os.Readlink(path.Join(target, "bin/bash")); err != nil || link != "sh" {
+		t.Errorf("Expected a symlink to sh, got %s (%v)", link, err)
+	}
+	for _, removed := range []string{"etc/removed", "etc/.wh.removed", ".wh..wh.aufs"} {
+		if _, err := os.Lstat(path.Join(target, removed)); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist", removed)
+		}
+	}
+
+	if err := removeCopy(target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("%s should have been removed", target)
+	}
+}
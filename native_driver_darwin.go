@@ -0,0 +1,56 @@
+package docker
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+func IsNativeInit() bool {
+	return false
+}
+
+type nativeDriver struct{}
+
+func newNativeDriver() (*nativeDriver, error) {
+	return nil, fmt.Errorf("The native driver is only supported on linux")
+}
+
+func (d *nativeDriver) Name() string {
+	return "native"
+}
+
+func (d *nativeDriver) Start(container *Container, cmd *exec.Cmd) error {
+	return fmt.Errorf("Not implemented")
+}
+
+func (d *nativeDriver) Kill(container *Container, sig int) error {
+	return fmt.Errorf("Not implemented")
+}
+
+func (d *nativeDriver) Wait(container *Container) (int, error) {
+	return -1, fmt.Errorf("Not implemented")
+}
+
+func (d *nativeDriver) Info(container *Container) (*ExecInfo, error) {
+	return nil, fmt.Errorf("Not implemented")
+}
+
+func (d *nativeDriver) Pause(container *Container) error {
+	return fmt.Errorf("Not implemented")
+}
+
+func (d *nativeDriver) Unpause(container *Container) error {
+	return fmt.Errorf("Not implemented")
+}
+
+func (d *nativeDriver) Exec(container *Container, args []string) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("Not implemented")
+}
+
+func (d *nativeDriver) Cleanup(container *Container) error {
+	return nil
+}
+
+func setupNativeContainer() {
+	panic("Not implemented")
+}
@@ -0,0 +1,563 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The native driver creates the namespaces and cgroups of containers itself,
+// without the lxc userspace tools. docker-init is started on the host in new
+// namespaces. Once the daemon has set up the cgroups and the network
+// interface, it sends a nativeInitConfig to docker-init through a pipe, and
+// docker-init sets up the filesystem of the container before carrying on as
+// /sbin/init.
+
+const (
+	nativeInitEnv    = "_DOCKER_NATIVE_INIT"
+	nativeCloneFlags = syscall.CLONE_NEWNS | syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET
+)
+
+// IsNativeInit returns true if the current process is docker-init started
+// by the native driver
+func IsNativeInit() bool {
+	return os.Getenv(nativeInitEnv) != ""
+}
+
+type nativeInitConfig struct {
+	Rootfs              string
+	Hostname            string
+	Mounts              []nativeMount
+	Tmpfs               []tmpfsMount
+	Veth                string // Name of the interface to rename to eth0
+	Address             string // Address of eth0, in CIDR notation
+	DroppedCapabilities []string
+	AppArmorProfile     string
+	ProcessLabel        string // SELinux label of the program
+	Seccomp             *seccompProfile
+}
+
+// A nativeMount is a bind mount of a path of the host to a path of the container
+type nativeMount struct {
+	Source      string
+	Target      string
+	Writable    bool
+	Propagation string // Mount propagation of the bind, private by default
+}
+
+var mountPropagations = map[string]uintptr{
+	"":         syscall.MS_REC | syscall.MS_PRIVATE,
+	"shared":   syscall.MS_SHARED,
+	"rshared":  syscall.MS_REC | syscall.MS_SHARED,
+	"slave":    syscall.MS_SLAVE,
+	"rslave":   syscall.MS_REC | syscall.MS_SLAVE,
+	"private":  syscall.MS_PRIVATE,
+	"rprivate": syscall.MS_REC | syscall.MS_PRIVATE,
+}
+
+type nativeDriver struct{}
+
+func newNativeDriver() (*nativeDriver, error) {
+	if _, err := exec.LookPath("ip"); err != nil {
+		return nil, fmt.Errorf("The native driver requires the ip command")
+	}
+	return &nativeDriver{}, nil
+}
+
+func (d *nativeDriver) Name() string {
+	return "native"
+}
+
+func (d *nativeDriver) subsystems(container *Container) []string {
+	subsystems := []string{"devices", "freezer"}
+	seen := map[string]bool{"devices": true, "freezer": true}
+	for _, setting := range cgroupSettings(container) {
+		if !seen[setting.Subsystem] {
+			seen[setting.Subsystem] = true
+			subsystems = append(subsystems, setting.Subsystem)
+		}
+	}
+	return subsystems
+}
+
+func (d *nativeDriver) Start(container *Container, cmd *exec.Cmd) error {
+	// The device whitelist of cgroup v2 takes an eBPF program, which the
+	// native driver doesn't load: only privileged containers can run
+	if utils.IsCgroupUnified() && (container.hostConfig == nil || !container.hostConfig.Privileged) {
+		return fmt.Errorf("Impossible to start the container %s: the native driver can't restrict its access to devices on cgroup v2 hosts. Use the lxc driver or -privileged.", container.ShortID())
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	cmd.Path = sysInitPath
+	cmd.ExtraFiles = []*os.File{r}
+	cmd.Env = append(os.Environ(), nativeInitEnv+"=1")
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags = nativeCloneFlags
+	if m := container.runtime.idMappings; m != nil {
+		// The mappings are written before docker-init is executed
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+		cmd.SysProcAttr.UidMappings = sysProcIDMaps(m.Uids)
+		cmd.SysProcAttr.GidMappings = sysProcIDMaps(m.Gids)
+	}
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	config, err := d.setup(container, cmd.Process.Pid)
+	if err == nil {
+		err = json.NewEncoder(w).Encode(config)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		removeCgroups(nativeCgroupRoot, container.ID, d.subsystems(container))
+		return err
+	}
+	return nil
+}
+
+func sysProcIDMaps(maps []idMap) []syscall.SysProcIDMap {
+	idMaps := []syscall.SysProcIDMap{}
+	for _, m := range maps {
+		idMaps = append(idMaps, syscall.SysProcIDMap{ContainerID: m.ContainerID, HostID: m.HostID, Size: m.Size})
+	}
+	return idMaps
+}
+
+// setup creates the cgroups and the network interface of the container
+// whose docker-init has the given pid
+func (d *nativeDriver) setup(container *Container, pid int) (*nativeInitConfig, error) {
+	subsystems := d.subsystems(container)
+	// Remove what a previous run could have left behind
+	removeCgroups(nativeCgroupRoot, container.ID, subsystems)
+	if err := applyCgroups(nativeCgroupRoot, container.ID, pid, subsystems, cgroupSettings(container)); err != nil {
+		return nil, err
+	}
+
+	config := &nativeInitConfig{
+		Rootfs:              container.RootfsPath(),
+		Hostname:            container.Config.Hostname,
+		DroppedCapabilities: droppedCapabilities(container),
+		Mounts: []nativeMount{
+			{container.SysInitPath, "/sbin/init", false, ""},
+			{container.InitDirPath(), controlDir, true, ""},
+			{container.ResolvConfPath, "/etc/resolv.conf", false, ""},
+			{container.HostsPath, "/etc/hosts", false, ""},
+		},
+		Tmpfs:           tmpfsMounts(container),
+		AppArmorProfile: container.AppArmorProfile,
+		ProcessLabel:    container.ProcessLabel,
+		Seccomp:         container.seccomp,
+	}
+	if config.Hostname == "" {
+		config.Hostname = container.ID[:12]
+	}
+	for _, device := range container.devices {
+		config.Mounts = append(config.Mounts, nativeMount{device.NodePath, device.PathInContainer, true, ""})
+	}
+	for virtualPath, realPath := range container.Volumes {
+		config.Mounts = append(config.Mounts, nativeMount{realPath, virtualPath, container.VolumesRW[virtualPath], container.VolumesPropagation[virtualPath]})
+	}
+
+	if settings := container.NetworkSettings; settings != nil && settings.IPAddress != "" {
+		hostVeth, peerVeth := "veth"+container.ID[:7], "veth"+container.ID[:7]+"p"
+		ip("link", "delete", hostVeth)
+		if _, err := ip("link", "add", "name", hostVeth, "mtu", "1500", "type", "veth", "peer", "name", peerVeth, "mtu", "1500"); err != nil {
+			return nil, err
+		}
+		if _, err := ip("link", "set", hostVeth, "master", settings.Bridge); err != nil {
+			return nil, err
+		}
+		if _, err := ip("link", "set", hostVeth, "up"); err != nil {
+			return nil, err
+		}
+		if _, err := ip("link", "set", peerVeth, "netns", strconv.Itoa(pid)); err != nil {
+			return nil, err
+		}
+		config.Veth = peerVeth
+		config.Address = fmt.Sprintf("%s/%d", settings.IPAddress, settings.IPPrefixLen)
+	}
+	return config, nil
+}
+
+func (d *nativeDriver) Kill(container *Container, sig int) error {
+	if container.State.Pid == 0 {
+		return fmt.Errorf("No process for container %s", container.ID)
+	}
+	return syscall.Kill(container.State.Pid, syscall.Signal(sig))
+}
+
+// The exit code of containers started by a previous daemon is unknown
+func (d *nativeDriver) Wait(container *Container) (int, error) {
+	for {
+		info, err := d.Info(container)
+		if err != nil {
+			return -1, err
+		}
+		if !info.Running {
+			return -1, nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func (d *nativeDriver) Info(container *Container) (*ExecInfo, error) {
+	pids, err := cgroupPids(nativeCgroupRoot, container.ID, "devices")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &ExecInfo{}, nil
+		}
+		return nil, err
+	}
+	info := &ExecInfo{Running: len(pids) > 0}
+	if info.Running {
+		info.Pid = container.State.Pid
+	}
+	return info, nil
+}
+
+func (d *nativeDriver) freeze(container *Container, state string) error {
+	dir, err := cgroupDir(nativeCgroupRoot, container.ID, "freezer")
+	if err != nil {
+		return err
+	}
+	if utils.IsCgroupUnified() {
+		frozen := "0"
+		if state == "FROZEN" {
+			frozen = "1"
+		}
+		return ioutil.WriteFile(path.Join(dir, "cgroup.freeze"), []byte(frozen), 0644)
+	}
+	return ioutil.WriteFile(path.Join(dir, "freezer.state"), []byte(state), 0644)
+}
+
+func (d *nativeDriver) Pause(container *Container) error {
+	return d.freeze(container, "FROZEN")
+}
+
+func (d *nativeDriver) Unpause(container *Container) error {
+	return d.freeze(container, "THAWED")
+}
+
+func (d *nativeDriver) Exec(container *Container, args []string) (*exec.Cmd, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("No command specified")
+	}
+	if container.State.Pid == 0 {
+		return nil, fmt.Errorf("No process for container %s", container.ID)
+	}
+	nsenterArgs := []string{"-t", strconv.Itoa(container.State.Pid), "-m", "-u", "-i", "-n", "-p", "--"}
+	return exec.Command("nsenter", append(nsenterArgs, args...)...), nil
+}
+
+func (d *nativeDriver) Cleanup(container *Container) error {
+	return removeCgroups(nativeCgroupRoot, container.ID, d.subsystems(container))
+}
+
+// setupNativeContainer runs in docker-init, in the namespaces created by the
+// native driver, and prepares the container as lxc-start would
+func setupNativeContainer() {
+	// The bounding set, no_new_privs and the AppArmor profile are set on the
+	// current thread: docker-init must execute or fork the program of the
+	// container from it
+	runtime.LockOSThread()
+
+	pipe := os.NewFile(3, "pipe")
+	config := &nativeInitConfig{}
+	if err := json.NewDecoder(pipe).Decode(config); err != nil {
+		log.Fatalf("Unable to read the container configuration: %v", err)
+	}
+	pipe.Close()
+
+	if config.Veth != "" {
+		if err := setupNativeNetwork(config.Veth, config.Address); err != nil {
+			log.Fatalf("Unable to set up networking: %v", err)
+		}
+	}
+	if err := syscall.Sethostname([]byte(config.Hostname)); err != nil {
+		log.Fatalf("Unable to set the hostname: %v", err)
+	}
+	if err := setupNativeRootfs(config.Rootfs, config.Mounts, config.Tmpfs); err != nil {
+		log.Fatalf("Unable to set up the root filesystem: %v", err)
+	}
+	for _, name := range config.DroppedCapabilities {
+		if err := dropBoundingCapability(name); err != nil {
+			log.Fatalf("Unable to drop capability %s: %v", name, err)
+		}
+	}
+	if profile := config.AppArmorProfile; profile != "" && profile != "unconfined" {
+		if err := setupAppArmorProfile(profile); err != nil {
+			log.Fatalf("Unable to set the AppArmor profile %s: %v", profile, err)
+		}
+	}
+	if label := config.ProcessLabel; label != "" {
+		if err := setupProcessLabel(label); err != nil {
+			log.Fatalf("Unable to set the SELinux label %s: %v", label, err)
+		}
+	}
+	// Filtering comes last, since the setup uses some of the blocked calls
+	if config.Seccomp != nil {
+		if err := installSeccomp(config.Seccomp); err != nil {
+			log.Fatalf("Unable to set up seccomp: %v", err)
+		}
+	}
+}
+
+// setupAppArmorProfile makes the next program executed by the current
+// thread run with the given profile. The thread must be locked.
+func setupAppArmorProfile(profile string) error {
+	attr := fmt.Sprintf("/proc/self/task/%d/attr/exec", syscall.Gettid())
+	return ioutil.WriteFile(attr, []byte("exec "+profile), 0)
+}
+
+// setupProcessLabel makes the next program executed by the current thread
+// run with the given SELinux label. The thread must be locked.
+func setupProcessLabel(label string) error {
+	attr := fmt.Sprintf("/proc/self/task/%d/attr/exec", syscall.Gettid())
+	return ioutil.WriteFile(attr, []byte(label), 0)
+}
+
+func setupNativeNetwork(veth, address string) error {
+	if _, err := ip("link", "set", veth, "name", "eth0"); err != nil {
+		return err
+	}
+	if _, err := ip("addr", "add", address, "dev", "eth0"); err != nil {
+		return err
+	}
+	if _, err := ip("link", "set", "eth0", "up"); err != nil {
+		return err
+	}
+	if _, err := ip("link", "set", "lo", "up"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func setupNativeRootfs(rootfs string, mounts []nativeMount, tmpfs []tmpfsMount) error {
+	// Don't propagate anything we do to the host, unless a bind shares its
+	// mounts with it, or receives those of the host
+	rootPropagation := mountPropagations["rprivate"]
+	for _, m := range mounts {
+		switch m.Propagation {
+		case "shared", "rshared":
+			rootPropagation = mountPropagations["rshared"]
+		case "slave", "rslave":
+			if rootPropagation != mountPropagations["rshared"] {
+				rootPropagation = mountPropagations["rslave"]
+			}
+		}
+	}
+	if err := syscall.Mount("", "/", "", rootPropagation, ""); err != nil {
+		return err
+	}
+	if rootPropagation != mountPropagations["rprivate"] {
+		// pivot_root refuses a new root whose parent mount is shared
+		parent, err := mountPointOf(rootfs)
+		if err != nil {
+			return err
+		}
+		if err := syscall.Mount("", parent, "", syscall.MS_PRIVATE, ""); err != nil {
+			return err
+		}
+	}
+	// pivot_root needs the new root to be a mount point
+	if err := syscall.Mount(rootfs, rootfs, "bind", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return err
+	}
+	// What the container mounts stays in the container, except in the binds
+	// which ask otherwise
+	if err := syscall.Mount("", rootfs, "", mountPropagations["rprivate"], ""); err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		// The symlinks of the image must not lead the binds outside of it
+		target, err := utils.FollowSymlinkInScope(path.Join(rootfs, m.Target), rootfs)
+		if err != nil {
+			return fmt.Errorf("%s: %s", m.Target, err)
+		}
+		if err := bindMount(m.Source, target, m.Writable); err != nil {
+			return fmt.Errorf("%s: %s", m.Target, err)
+		}
+		if err := syscall.Mount("", target, "", mountPropagations[m.Propagation], ""); err != nil {
+			return fmt.Errorf("%s: %s", m.Target, err)
+		}
+	}
+
+	if err := syscall.Chdir(rootfs); err != nil {
+		return err
+	}
+	// Stack the old root on top of the new one, then detach it
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %s", err)
+	}
+	// Make sure detaching the old root doesn't reach the host
+	if err := syscall.Mount("", ".", "", mountPropagations["rslave"], ""); err != nil {
+		return err
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return err
+	}
+	if err := syscall.Chdir("/"); err != nil {
+		return err
+	}
+
+	//  WARNING: procfs and sysfs are known attack vectors, see LxcTemplate
+	for _, m := range []struct {
+		source, target, fstype string
+		flags                  uintptr
+		data                   string
+	}{
+		{"proc", "/proc", "proc", syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC, ""},
+		{"sysfs", "/sys", "sysfs", syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC, ""},
+		{"devpts", "/dev/pts", "devpts", syscall.MS_NOSUID | syscall.MS_NOEXEC, "newinstance,ptmxmode=0666"},
+	} {
+		if err := os.MkdirAll(m.target, 0755); err != nil {
+			return err
+		}
+		if err := syscall.Mount(m.source, m.target, m.fstype, m.flags, m.data); err != nil {
+			return fmt.Errorf("%s: %s", m.target, err)
+		}
+	}
+	// Use the ptmx of our own devpts instance
+	if _, err := os.Stat("/dev/ptmx"); err == nil {
+		if err := syscall.Mount("/dev/pts/ptmx", "/dev/ptmx", "bind", syscall.MS_BIND, ""); err != nil {
+			return err
+		}
+	}
+	for _, m := range tmpfs {
+		flags, data := parseMountOptions(m.Options)
+		if err := syscall.Mount("tmpfs", m.Target, "tmpfs", flags, data); err != nil {
+			return fmt.Errorf("%s: %s", m.Target, err)
+		}
+	}
+	return nil
+}
+
+// parseMountOptions splits options of mount(8) into mount flags and the
+// options left to the filesystem
+func parseMountOptions(options string) (uintptr, string) {
+	flagOptions := map[string]struct {
+		clear bool
+		flag  uintptr
+	}{
+		"ro":       {false, syscall.MS_RDONLY},
+		"rw":       {true, syscall.MS_RDONLY},
+		"nosuid":   {false, syscall.MS_NOSUID},
+		"suid":     {true, syscall.MS_NOSUID},
+		"nodev":    {false, syscall.MS_NODEV},
+		"dev":      {true, syscall.MS_NODEV},
+		"noexec":   {false, syscall.MS_NOEXEC},
+		"exec":     {true, syscall.MS_NOEXEC},
+		"noatime":  {false, syscall.MS_NOATIME},
+		"atime":    {true, syscall.MS_NOATIME},
+		"sync":     {false, syscall.MS_SYNCHRONOUS},
+		"async":    {true, syscall.MS_SYNCHRONOUS},
+		"defaults": {false, 0},
+	}
+	var flags uintptr
+	data := []string{}
+	for _, option := range strings.Split(options, ",") {
+		if option == "" {
+			continue
+		}
+		if f, exists := flagOptions[option]; exists {
+			if f.clear {
+				flags &^= f.flag
+			} else {
+				flags |= f.flag
+			}
+		} else {
+			data = append(data, option)
+		}
+	}
+	return flags, strings.Join(data, ",")
+}
+
+func bindMount(source, target string, writable bool) error {
+	stat, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	// The mount point must exist and be of the same kind as the source
+	if _, err := os.Stat(target); os.IsNotExist(err) {
+		if stat.IsDir() {
+			err = os.MkdirAll(target, 0755)
+		} else {
+			if err = os.MkdirAll(path.Dir(target), 0755); err == nil {
+				var f *os.File
+				if f, err = os.Create(target); err == nil {
+					f.Close()
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := syscall.Mount(source, target, "bind", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return err
+	}
+	if !writable {
+		return syscall.Mount(source, target, "bind", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, "")
+	}
+	return nil
+}
+
+// mountPointOf returns the mount point of the filesystem holding `p`
+func mountPointOf(p string) (string, error) {
+	output, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return "", err
+	}
+	mountpoint := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.Split(line, " ")
+		if len(parts) != 6 {
+			continue
+		}
+		if (p == parts[1] || strings.HasPrefix(p, strings.TrimSuffix(parts[1], "/")+"/")) && len(parts[1]) > len(mountpoint) {
+			mountpoint = parts[1]
+		}
+	}
+	if mountpoint == "" {
+		return "", fmt.Errorf("Unable to find the mount point of %s", p)
+	}
+	return mountpoint, nil
+}
+
+// dropBoundingCapability removes a capability from the bounding set, so that
+// the programs executed afterwards can't get it back
+func dropBoundingCapability(name string) error {
+	n, exists := capabilityNumbers[name]
+	if !exists {
+		return fmt.Errorf("Unknown capability: %s", name)
+	}
+	const PR_CAPBSET_DROP = 24
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, PR_CAPBSET_DROP, uintptr(n), 0); errno != 0 {
+		// Capabilities unknown to the running kernel don't need to be dropped
+		if errno == syscall.EINVAL {
+			return nil
+		}
+		return errno
+	}
+	return nil
+}
@@ -18,6 +18,25 @@ func TestIptables(t *testing.T) {
 	}
 }
 
+func TestDisabledNetwork(t *testing.T) {
+	manager, err := newNetworkManager(DisableNetworkBridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iface, err := manager.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iface.IPNet.IP != nil {
+		t.Errorf("A disabled interface should not have an address, got %s", iface.IPNet.IP)
+	}
+	if _, err := iface.AllocatePort("80"); err == nil {
+		t.Error("Allocating a port on a disabled interface should fail")
+	}
+	// Releasing is a no-op
+	iface.Release()
+}
+
 func TestParseNat(t *testing.T) {
 	if nat, err := parseNat("4500"); err == nil {
 		if nat.Frontend != 0 || nat.Backend != 4500 || nat.Proto != "tcp" {
@@ -0,0 +1,7 @@
+package docker
+
+import "errors"
+
+func notifyOnOOM(dir string) (<-chan struct{}, error) {
+	return nil, errors.New("OOM notifications are not implemented on darwin")
+}
@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+)
+
+// notifyOnOOM registers an eventfd on the memory.oom_control file of the
+// memory cgroup at `dir`. The returned channel receives a value each time
+// the OOM killer fires inside the cgroup, and is closed when the cgroup
+// is removed.
+func notifyOnOOM(dir string) (<-chan struct{}, error) {
+	oomControl, err := os.Open(path.Join(dir, "memory.oom_control"))
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.RawSyscall(syscall.SYS_EVENTFD2, 0, syscall.O_CLOEXEC, 0)
+	if errno != 0 {
+		oomControl.Close()
+		return nil, errno
+	}
+	eventfd := os.NewFile(fd, "eventfd")
+
+	data := []byte(fmt.Sprintf("%d %d", eventfd.Fd(), oomControl.Fd()))
+	if err := ioutil.WriteFile(path.Join(dir, "cgroup.event_control"), data, 0700); err != nil {
+		eventfd.Close()
+		oomControl.Close()
+		return nil, err
+	}
+
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer func() {
+			close(ch)
+			eventfd.Close()
+			oomControl.Close()
+		}()
+		buf := make([]byte, 8)
+		for {
+			if _, err := eventfd.Read(buf); err != nil {
+				return
+			}
+			// The eventfd is also signaled when the cgroup is removed
+			if _, err := os.Lstat(path.Join(dir, "memory.oom_control")); os.IsNotExist(err) {
+				return
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -8,15 +8,20 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"path"
 	"sort"
-	"strings"
 )
 
 type Capabilities struct {
-	MemoryLimit bool
-	SwapLimit   bool
+	MemoryLimit   bool
+	SwapLimit     bool
+	CpuCfsQuota   bool
+	Cpuset        bool
+	BlkioWeight   bool
+	BlkioThrottle bool
+	PidsLimit     bool
+	AppArmor      bool
+	Seccomp       bool
 }
 
 type Runtime struct {
@@ -28,9 +33,11 @@ type Runtime struct {
 	repositories   *TagStore
 	idIndex        *utils.TruncIndex
 	capabilities   *Capabilities
+	execDriver     ExecDriver
 	kernelVersion  *utils.KernelVersionInfo
 	autoRestart    bool
-	volumes        *Graph
+	volumes        *VolumeStore
+	idMappings     *idMappings // Set with -userns-remap
 	srv            *Server
 	Dns            []string
 }
@@ -119,6 +126,10 @@ func (runtime *Runtime) Register(container *Container) error {
 	} else {
 		container.stdinPipe = utils.NopWriteCloser(ioutil.Discard) // Silently drop stdin
 	}
+	container.VolumeNames = runtime.volumes.VolumeNames(container)
+	if err := runtime.volumes.Reference(container); err != nil {
+		return err
+	}
 	// done
 	runtime.containers.PushBack(container)
 	runtime.idIndex.Add(container.ID)
@@ -130,19 +141,18 @@ func (runtime *Runtime) Register(container *Container) error {
 	// FIXME: if the container is supposed to be running but is not, auto restart it?
 	//        if so, then we need to restart monitor and init a new lock
 	// If the container is supposed to be running, make sure of it
-	if container.State.Running {
-		output, err := exec.Command("lxc-info", "-n", container.ID).CombinedOutput()
+	if container.State.Running && !container.hasControl() {
+		info, err := runtime.execDriver.Info(container)
 		if err != nil {
 			return err
 		}
-		if !strings.Contains(string(output), "RUNNING") {
+		if !info.Running {
 			utils.Debugf("Container %s was supposed to be running be is not.", container.ID)
 			if runtime.autoRestart {
 				utils.Debugf("Restarting")
 				container.State.Ghost = false
 				container.State.setStopped(0)
-				hostConfig := &HostConfig{}
-				if err := container.Start(hostConfig); err != nil {
+				if err := container.Start(nil); err != nil {
 					return err
 				}
 				nomonitor = true
@@ -199,6 +209,9 @@ func (runtime *Runtime) Destroy(container *Container) error {
 	// Deregister the container before removing its directory, to avoid race conditions
 	runtime.idIndex.Delete(container.ID)
 	runtime.containers.Remove(element)
+	if err := runtime.volumes.Release(container); err != nil {
+		return fmt.Errorf("Unable to release the volumes of %v: %v", container.ID, err)
+	}
 	if err := os.RemoveAll(container.root); err != nil {
 		return fmt.Errorf("Unable to remove filesystem for %v: %v", container.ID, err)
 	}
@@ -219,28 +232,90 @@ func (runtime *Runtime) restore() error {
 		}
 		utils.Debugf("Loaded container %v", container.ID)
 	}
-	return nil
+	// Containers removed by hand don't use their volumes anymore
+	return runtime.volumes.ReleaseUnknown(runtime.List())
 }
 
 func (runtime *Runtime) UpdateCapabilities(quiet bool) {
-	if cgroupMemoryMountpoint, err := utils.FindCgroupMountpoint("memory"); err != nil {
+	if utils.IsCgroupUnified() {
+		runtime.capabilities = unifiedCapabilities()
 		if !quiet {
-			log.Printf("WARNING: %s\n", err)
+			for _, controller := range []struct {
+				supported bool
+				name      string
+			}{
+				{runtime.capabilities.MemoryLimit, "memory limit"},
+				{runtime.capabilities.SwapLimit, "swap limit"},
+				{runtime.capabilities.CpuCfsQuota, "CPU quotas"},
+				{runtime.capabilities.Cpuset, "cpusets"},
+				{runtime.capabilities.BlkioWeight, "block IO weights"},
+				{runtime.capabilities.BlkioThrottle, "block IO throttling"},
+				{runtime.capabilities.PidsLimit, "pids limits"},
+			} {
+				if !controller.supported {
+					log.Printf("WARNING: Your kernel does not support cgroup %s.", controller.name)
+				}
+			}
 		}
 	} else {
-		_, err1 := ioutil.ReadFile(path.Join(cgroupMemoryMountpoint, "memory.limit_in_bytes"))
-		_, err2 := ioutil.ReadFile(path.Join(cgroupMemoryMountpoint, "memory.soft_limit_in_bytes"))
-		runtime.capabilities.MemoryLimit = err1 == nil && err2 == nil
-		if !runtime.capabilities.MemoryLimit && !quiet {
-			log.Printf("WARNING: Your kernel does not support cgroup memory limit.")
+		if cgroupMemoryMountpoint, err := utils.FindCgroupMountpoint("memory"); err != nil {
+			if !quiet {
+				log.Printf("WARNING: %s\n", err)
+			}
+		} else {
+			_, err1 := ioutil.ReadFile(path.Join(cgroupMemoryMountpoint, "memory.limit_in_bytes"))
+			_, err2 := ioutil.ReadFile(path.Join(cgroupMemoryMountpoint, "memory.soft_limit_in_bytes"))
+			runtime.capabilities.MemoryLimit = err1 == nil && err2 == nil
+			if !runtime.capabilities.MemoryLimit && !quiet {
+				log.Printf("WARNING: Your kernel does not support cgroup memory limit.")
+			}
+
+			_, err = ioutil.ReadFile(path.Join(cgroupMemoryMountpoint, "memory.memsw.limit_in_bytes"))
+			runtime.capabilities.SwapLimit = err == nil
+			if !runtime.capabilities.SwapLimit && !quiet {
+				log.Printf("WARNING: Your kernel does not support cgroup swap limit.")
+			}
 		}
 
-		_, err = ioutil.ReadFile(path.Join(cgroupMemoryMountpoint, "memory.memsw.limit_in_bytes"))
-		runtime.capabilities.SwapLimit = err == nil
-		if !runtime.capabilities.SwapLimit && !quiet {
-			log.Printf("WARNING: Your kernel does not support cgroup swap limit.")
+		for _, controller := range []struct {
+			capability *bool
+			subsystem  string
+			files      []string
+			name       string
+		}{
+			{&runtime.capabilities.CpuCfsQuota, "cpu", []string{"cpu.cfs_quota_us", "cpu.cfs_period_us"}, "CPU quotas"},
+			{&runtime.capabilities.Cpuset, "cpuset", []string{"cpuset.cpus", "cpuset.mems"}, "cpusets"},
+			{&runtime.capabilities.BlkioWeight, "blkio", []string{"blkio.weight"}, "block IO weights"},
+			{&runtime.capabilities.BlkioThrottle, "blkio", []string{"blkio.throttle.read_bps_device", "blkio.throttle.write_bps_device"}, "block IO throttling"},
+			// The root of the pids hierarchy has no pids.max
+			{&runtime.capabilities.PidsLimit, "pids", nil, "pids limits"},
+		} {
+			*controller.capability = cgroupFilesExist(controller.subsystem, controller.files...)
+			if !*controller.capability && !quiet {
+				log.Printf("WARNING: Your kernel does not support cgroup %s.", controller.name)
+			}
+		}
+	}
+
+	runtime.capabilities.AppArmor = appArmorEnabled()
+	runtime.capabilities.Seccomp = seccompSupported()
+	if !runtime.capabilities.Seccomp && !quiet {
+		log.Printf("WARNING: Your kernel does not support seccomp. Containers will run without the default seccomp profile.")
+	}
+}
+
+// cgroupFilesExist returns true if the subsystem is mounted and has all the files
+func cgroupFilesExist(subsystem string, files ...string) bool {
+	mountpoint, err := utils.FindCgroupMountpoint(subsystem)
+	if err != nil {
+		return false
+	}
+	for _, file := range files {
+		if _, err := os.Stat(path.Join(mountpoint, file)); err != nil {
+			return false
 		}
 	}
+	return true
 }
 
 // FIXME: harmonize with NewGraph()
@@ -264,6 +339,17 @@ func NewRuntime(flGraphPath string, autoRestart bool, dns []string) (*Runtime, e
 }
 
 func NewRuntimeFromDirectory(root string, autoRestart bool) (*Runtime, error) {
+	var mappings *idMappings
+	if UsernsRemap != "" {
+		m, err := loadIDMappings(UsernsRemap)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to set up user namespace remapping: %s", err)
+		}
+		mappings = m
+		// Layers are shifted for a given remapping, keep them apart
+		uid, gid := mappings.RootPair()
+		root = path.Join(root, fmt.Sprintf("%d.%d", uid, gid))
+	}
 	runtimeRepo := path.Join(root, "containers")
 
 	if err := os.MkdirAll(runtimeRepo, 0700); err != nil && !os.IsExist(err) {
@@ -274,10 +360,21 @@ func NewRuntimeFromDirectory(root string, autoRestart bool) (*Runtime, error) {
 	if err != nil {
 		return nil, err
 	}
-	volumes, err := NewGraph(path.Join(root, "volumes"))
+	volumes, err := NewVolumeStore(path.Join(root, "volumes"))
 	if err != nil {
 		return nil, err
 	}
+	if mappings != nil {
+		// The root of the containers must be able to reach their
+		// directories and their volumes
+		for _, dir := range []string{path.Dir(root), root, runtimeRepo, volumes.root} {
+			if err := os.Chmod(dir, 0711); err != nil {
+				return nil, err
+			}
+		}
+		g.idMappings = mappings
+		volumes.idMappings = mappings
+	}
 	repositories, err := NewTagStore(path.Join(root, "repositories"), g)
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't create Tag store: %s", err)
@@ -289,6 +386,13 @@ func NewRuntimeFromDirectory(root string, autoRestart bool) (*Runtime, error) {
 	if err != nil {
 		return nil, err
 	}
+	if ExecDriverName == "" {
+		ExecDriverName = DefaultExecDriver
+	}
+	execDriver, err := newExecDriver(ExecDriverName)
+	if err != nil {
+		return nil, err
+	}
 	runtime := &Runtime{
 		root:           root,
 		repository:     runtimeRepo,
@@ -298,8 +402,10 @@ func NewRuntimeFromDirectory(root string, autoRestart bool) (*Runtime, error) {
 		repositories:   repositories,
 		idIndex:        utils.NewTruncIndex(),
 		capabilities:   &Capabilities{},
+		execDriver:     execDriver,
 		autoRestart:    autoRestart,
 		volumes:        volumes,
+		idMappings:     mappings,
 	}
 
 	if err := runtime.restore(); err != nil {
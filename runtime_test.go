@@ -9,6 +9,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,11 +22,12 @@ const (
 	unitTestImageName	= "docker-test-image"
 	unitTestImageID		= "83599e29c455eb719f77d799bc7c51521b9551972f5a850d7ad265bc1b5292f6" // 1.0
 	unitTestNetworkBridge	= "testdockbr0"
-	unitTestStoreBase	= "/var/lib/docker/unit-tests"
 	testDaemonAddr		= "127.0.0.1:4270"
 	testDaemonProto		= "tcp"
 )
 
+var unitTestStoreBase = "/var/lib/docker/unit-tests"
+
 var globalRuntime *Runtime
 
 func nuke(runtime *Runtime) error {
@@ -69,17 +71,23 @@ func layerArchive(tarfile string) (io.Reader, error) {
 
 func init() {
 	// Hack to run sys init during unit testing
-	if utils.SelfPath() == "/sbin/init" {
+	if IsNativeInit() || utils.SelfPath() == "/sbin/init" {
 		SysInit()
 		return
 	}
 
 	if uid := syscall.Geteuid(); uid != 0 {
-		log.Fatal("docker tests need to be run as root")
+		// Without root, run the programs of containers as plain processes
+		// of the tests, in a copy of their image, without networking
+		log.Printf("Not running as root: using the fake execution driver")
+		ExecDriverName = "fake"
+		NetworkBridgeIface = DisableNetworkBridge
+		CopyMount = true
+		unitTestStoreBase = path.Join(os.TempDir(), fmt.Sprintf("docker-unit-tests-%d", uid))
+	} else {
+		NetworkBridgeIface = unitTestNetworkBridge
 	}
 
-	NetworkBridgeIface = unitTestNetworkBridge
-
 	// Make it our Store root
 	runtime, err := NewRuntimeFromDirectory(unitTestStoreBase, false)
 	if err != nil {
@@ -0,0 +1,226 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall"
+)
+
+// A seccompProfile filters the system calls of a container. It uses the
+// action names of libseccomp, e.g.:
+//
+//	{
+//	    "defaultAction": "SCMP_ACT_ALLOW",
+//	    "syscalls": [
+//	        {"name": "ptrace", "action": "SCMP_ACT_ERRNO"}
+//	    ]
+//	}
+type seccompProfile struct {
+	DefaultAction string
+	Syscalls      []seccompRule
+}
+
+type seccompRule struct {
+	Name   string
+	Action string
+}
+
+const (
+	seccompActAllow = "SCMP_ACT_ALLOW"
+	seccompActErrno = "SCMP_ACT_ERRNO" // Fail with EPERM
+	seccompActKill  = "SCMP_ACT_KILL"
+)
+
+// defaultSeccompBlocked are the system calls denied to containers which
+// are not privileged. They are mostly meant for the administration of the
+// host, or already require a capability that containers don't have.
+var defaultSeccompBlocked = []string{
+	"acct",
+	"add_key",
+	"bpf",
+	"clock_adjtime",
+	"clock_settime",
+	"create_module",
+	"delete_module",
+	"finit_module",
+	"get_kernel_syms",
+	"init_module",
+	"ioperm",
+	"iopl",
+	"kcmp",
+	"kexec_file_load",
+	"kexec_load",
+	"keyctl",
+	"lookup_dcookie",
+	"mount",
+	"name_to_handle_at",
+	"nfsservctl",
+	"open_by_handle_at",
+	"perf_event_open",
+	"pivot_root",
+	"process_vm_readv",
+	"process_vm_writev",
+	"ptrace",
+	"query_module",
+	"quotactl",
+	"reboot",
+	"request_key",
+	"setns",
+	"settimeofday",
+	"swapoff",
+	"swapon",
+	"sysfs",
+	"_sysctl",
+	"umount2",
+	"unshare",
+	"uselib",
+	"userfaultfd",
+	"ustat",
+}
+
+// defaultSeccompProfile returns the profile of the containers which don't
+// select one with -security-opt, given their capabilities. Containers with
+// CAP_SYS_ADMIN can mount filesystems, e.g. with FUSE.
+func defaultSeccompProfile(capabilities []string) *seccompProfile {
+	allowed := make(map[string]bool)
+	for _, capability := range capabilities {
+		if capability == "sys_admin" {
+			allowed["mount"] = true
+			allowed["umount2"] = true
+		}
+	}
+	profile := &seccompProfile{DefaultAction: seccompActAllow}
+	for _, name := range defaultSeccompBlocked {
+		if allowed[name] {
+			continue
+		}
+		profile.Syscalls = append(profile.Syscalls, seccompRule{name, seccompActErrno})
+	}
+	return profile
+}
+
+// parseSeccompProfile decodes and validates a profile in JSON
+func parseSeccompProfile(data []byte) (*seccompProfile, error) {
+	profile := &seccompProfile{}
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, fmt.Errorf("Invalid seccomp profile: %s", err)
+	}
+	if _, err := seccompReturn(profile.DefaultAction); err != nil {
+		return nil, err
+	}
+	for _, rule := range profile.Syscalls {
+		if _, exists := seccompSyscalls[rule.Name]; !exists {
+			return nil, fmt.Errorf("Unknown syscall in seccomp profile: %s", rule.Name)
+		}
+		if _, err := seccompReturn(rule.Action); err != nil {
+			return nil, err
+		}
+	}
+	return profile, nil
+}
+
+// seccompReturn returns the value a seccomp filter returns for an action
+func seccompReturn(action string) (uint32, error) {
+	const (
+		SECCOMP_RET_KILL  = 0x00000000
+		SECCOMP_RET_ERRNO = 0x00050000
+		SECCOMP_RET_ALLOW = 0x7fff0000
+	)
+	switch action {
+	case seccompActAllow:
+		return SECCOMP_RET_ALLOW, nil
+	case seccompActErrno:
+		return SECCOMP_RET_ERRNO | uint32(syscall.EPERM), nil
+	case seccompActKill:
+		return SECCOMP_RET_KILL, nil
+	}
+	return 0, fmt.Errorf("Invalid seccomp action: %s", action)
+}
+
+// sockFilter is an instruction of a classic BPF program, as struct sock_filter
+type sockFilter struct {
+	Code uint16
+	Jt   uint8
+	Jf   uint8
+	K    uint32
+}
+
+// filter compiles the profile into a BPF program for SECCOMP_MODE_FILTER
+func (profile *seccompProfile) filter() ([]sockFilter, error) {
+	const (
+		BPF_LD_W_ABS = 0x20 // BPF_LD | BPF_W | BPF_ABS
+		BPF_JEQ_K    = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+		BPF_JGE_K    = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+		BPF_RET_K    = 0x06 // BPF_RET | BPF_K
+
+		X32_SYSCALL_BIT = 0x40000000
+
+		// Offsets in struct seccomp_data
+		offsetNr   = 0
+		offsetArch = 4
+	)
+	defaultRet, err := seccompReturn(profile.DefaultAction)
+	if err != nil {
+		return nil, err
+	}
+	eperm, _ := seccompReturn(seccompActErrno)
+
+	program := []sockFilter{
+		// System calls made with another ABI, e.g. by i386 binaries, would
+		// have other numbers: they fail rather than killing the program
+		{BPF_LD_W_ABS, 0, 0, offsetArch},
+		{BPF_JEQ_K, 1, 0, seccompAuditArch},
+		{BPF_RET_K, 0, 0, eperm},
+		{BPF_LD_W_ABS, 0, 0, offsetNr},
+		{BPF_JGE_K, 0, 1, X32_SYSCALL_BIT},
+		{BPF_RET_K, 0, 0, eperm},
+	}
+	for _, rule := range profile.Syscalls {
+		nr, exists := seccompSyscalls[rule.Name]
+		if !exists {
+			return nil, fmt.Errorf("Unknown syscall in seccomp profile: %s", rule.Name)
+		}
+		ret, err := seccompReturn(rule.Action)
+		if err != nil {
+			return nil, err
+		}
+		if ret == defaultRet {
+			continue
+		}
+		program = append(program,
+			sockFilter{BPF_JEQ_K, 0, 1, nr},
+			sockFilter{BPF_RET_K, 0, 0, ret},
+		)
+	}
+	return append(program, sockFilter{BPF_RET_K, 0, 0, defaultRet}), nil
+}
+
+// lxcPolicy writes the profile in the format of lxc.seccomp
+func (profile *seccompProfile) lxcPolicy() ([]byte, error) {
+	lxcActions := map[string]string{
+		seccompActAllow: "allow",
+		seccompActErrno: fmt.Sprintf("errno %d", syscall.EPERM),
+		seccompActKill:  "kill",
+	}
+	defaultAction, exists := lxcActions[profile.DefaultAction]
+	if !exists {
+		return nil, fmt.Errorf("Invalid seccomp action: %s", profile.DefaultAction)
+	}
+	policy := "2\n"
+	if profile.DefaultAction == seccompActAllow {
+		policy += "blacklist\n"
+	} else {
+		policy += "whitelist " + defaultAction + "\n"
+	}
+	for _, rule := range profile.Syscalls {
+		action, exists := lxcActions[rule.Action]
+		if !exists {
+			return nil, fmt.Errorf("Invalid seccomp action: %s", rule.Action)
+		}
+		if rule.Action == profile.DefaultAction {
+			continue
+		}
+		policy += rule.Name + " " + action + "\n"
+	}
+	return []byte(policy), nil
+}
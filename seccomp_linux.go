@@ -0,0 +1,39 @@
+package docker
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// installSeccomp applies the profile to every thread of the current process
+// and to the programs it executes. The programs can't gain privileges
+// through setuid binaries or file capabilities from then on.
+func installSeccomp(profile *seccompProfile) error {
+	if sysSeccomp == 0 {
+		return fmt.Errorf("seccomp filters are not supported on this architecture")
+	}
+	program, err := profile.filter()
+	if err != nil {
+		return err
+	}
+	const (
+		PR_SET_NO_NEW_PRIVS       = 38
+		SECCOMP_SET_MODE_FILTER   = 1
+		SECCOMP_FILTER_FLAG_TSYNC = 1
+	)
+	// Without it, the filter could be escaped by executing a program which
+	// gains the privileges to remove it
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, PR_SET_NO_NEW_PRIVS, 1, 0); errno != 0 {
+		return errno
+	}
+	// struct sock_fprog
+	fprog := struct {
+		Len    uint16
+		Filter *sockFilter
+	}{uint16(len(program)), &program[0]}
+	if _, _, errno := syscall.RawSyscall(sysSeccomp, SECCOMP_SET_MODE_FILTER, SECCOMP_FILTER_FLAG_TSYNC, uintptr(unsafe.Pointer(&fprog))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !amd64
+// +build !amd64
+
+package docker
+
+// Seccomp filters are only built for x86_64: the other architectures have
+// other system call numbers, and containers run unconfined on them
+const (
+	sysSeccomp       = 0
+	seccompAuditArch = 0
+)
+
+var seccompSyscalls = map[string]uint32{}
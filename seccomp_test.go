@@ -0,0 +1,143 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSeccompProfile(t *testing.T) {
+	profile, err := parseSeccompProfile([]byte(`{"defaultAction": "SCMP_ACT_ERRNO", "syscalls": [{"name": "read", "action": "SCMP_ACT_ALLOW"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.DefaultAction != seccompActErrno || len(profile.Syscalls) != 1 || profile.Syscalls[0] != (seccompRule{"read", seccompActAllow}) {
+		t.Fatalf("Unexpected profile: %v", profile)
+	}
+
+	for _, invalid := range []string{
+		`not json`,
+		`{"defaultAction": "SCMP_ACT_TRACE"}`,
+		`{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"name": "nosuchcall", "action": "SCMP_ACT_ERRNO"}]}`,
+		`{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"name": "mount", "action": "deny"}]}`,
+	} {
+		if _, err := parseSeccompProfile([]byte(invalid)); err == nil {
+			t.Errorf("%s should be an invalid profile", invalid)
+		}
+	}
+}
+
+func TestSeccompFilter(t *testing.T) {
+	profile := &seccompProfile{
+		DefaultAction: seccompActAllow,
+		Syscalls: []seccompRule{
+			{"mount", seccompActErrno},
+			{"read", seccompActAllow},
+			{"kexec_load", seccompActKill},
+		},
+	}
+	program, err := profile.filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 6 instructions of prologue, 2 per rule which differs from the default, and the default
+	if len(program) != 6+2*2+1 {
+		t.Fatalf("Unexpected program length: %d", len(program))
+	}
+	if program[6].K != seccompSyscalls["mount"] || program[7].K != 0x00050001 {
+		t.Errorf("mount should fail with EPERM: %v %v", program[6], program[7])
+	}
+	if program[8].K != seccompSyscalls["kexec_load"] || program[9].K != 0 {
+		t.Errorf("kexec_load should kill the process: %v %v", program[8], program[9])
+	}
+	if last := program[len(program)-1]; last.K != 0x7fff0000 {
+		t.Errorf("The default action should allow the call: %v", last)
+	}
+}
+
+// runSeccompFilter returns what the program returns for a system call
+func runSeccompFilter(t *testing.T, program []sockFilter, arch, nr uint32) uint32 {
+	var acc uint32
+	for pc := 0; pc < len(program); pc++ {
+		insn := program[pc]
+		switch insn.Code {
+		case 0x20:
+			if insn.K == 4 {
+				acc = arch
+			} else {
+				acc = nr
+			}
+		case 0x15:
+			if acc == insn.K {
+				pc += int(insn.Jt)
+			} else {
+				pc += int(insn.Jf)
+			}
+		case 0x35:
+			if acc >= insn.K {
+				pc += int(insn.Jt)
+			} else {
+				pc += int(insn.Jf)
+			}
+		case 0x06:
+			return insn.K
+		default:
+			t.Fatalf("Unexpected instruction: %v", insn)
+		}
+	}
+	t.Fatal("The program doesn't return")
+	return 0
+}
+
+func TestSeccompFilterArch(t *testing.T) {
+	program, err := defaultSeccompProfile(nil).filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const AUDIT_ARCH_I386 = 0x40000003
+	if ret := runSeccompFilter(t, program, AUDIT_ARCH_I386, seccompSyscalls["read"]); ret != 0x00050001 {
+		t.Errorf("System calls of another architecture should fail with EPERM, got %#x", ret)
+	}
+	if ret := runSeccompFilter(t, program, seccompAuditArch, 0x40000000); ret != 0x00050001 {
+		t.Errorf("x32 system calls should fail with EPERM, got %#x", ret)
+	}
+}
+
+func TestSeccompLxcPolicy(t *testing.T) {
+	policy, err := defaultSeccompProfile(nil).lxcPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(policy)), "\n")
+	if lines[0] != "2" || lines[1] != "blacklist" {
+		t.Fatalf("Unexpected header: %v", lines[:2])
+	}
+	if len(lines) != 2+len(defaultSeccompBlocked) {
+		t.Fatalf("Expected %d rules, got %d", len(defaultSeccompBlocked), len(lines)-2)
+	}
+	for _, name := range []string{"mount", "ptrace", "kexec_load"} {
+		if !strings.Contains(string(policy), "\n"+name+" errno 1\n") {
+			t.Errorf("%s should be blocked: %s", name, policy)
+		}
+	}
+
+	profile := &seccompProfile{DefaultAction: seccompActKill, Syscalls: []seccompRule{{"read", seccompActAllow}}}
+	policy, err = profile.lxcPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(policy) != "2\nwhitelist kill\nread allow\n" {
+		t.Fatalf("Unexpected policy: %q", policy)
+	}
+}
+
+func TestDefaultSeccompProfileSysAdmin(t *testing.T) {
+	profile := defaultSeccompProfile([]string{"chown", "sys_admin"})
+	if len(profile.Syscalls) != len(defaultSeccompBlocked)-2 {
+		t.Fatalf("Expected %d rules, got %d", len(defaultSeccompBlocked)-2, len(profile.Syscalls))
+	}
+	for _, rule := range profile.Syscalls {
+		if rule.Name == "mount" || rule.Name == "umount2" {
+			t.Errorf("%s should be allowed with CAP_SYS_ADMIN", rule.Name)
+		}
+	}
+}
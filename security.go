@@ -0,0 +1,106 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// parseSecurityOpt parses a security option of the form apparmor=PROFILE or
+// seccomp=PROFILE. The seccomp profile is either unconfined or a JSON
+// document, which the CLI reads from a file.
+func parseSecurityOpt(opt string) (string, string, error) {
+	parts := strings.SplitN(opt, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("Invalid security option: %s. It needs to be of the form apparmor=PROFILE or seccomp=PROFILE.", opt)
+	}
+	switch parts[0] {
+	case "apparmor", "seccomp":
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("Unknown security option: %s", parts[0])
+}
+
+// appArmorEnabled returns true if the host confines processes with AppArmor
+func appArmorEnabled() bool {
+	enabled, err := ioutil.ReadFile("/sys/module/apparmor/parameters/enabled")
+	return err == nil && bytes.HasPrefix(enabled, []byte("Y"))
+}
+
+// seccompSupported returns true if the kernel can filter system calls, and
+// docker knows the system calls of the architecture
+func seccompSupported() bool {
+	if sysSeccomp == 0 {
+		return false
+	}
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "Seccomp:") {
+			return true
+		}
+	}
+	return false
+}
+
+// setupSecurity selects the AppArmor and the seccomp profiles of the
+// container. Privileged containers are unconfined unless told otherwise.
+// The others get the default seccomp profile and the AppArmor profile the
+// execution driver uses by default.
+func (container *Container) setupSecurity(hostConfig *HostConfig) error {
+	container.AppArmorProfile = ""
+	container.SeccompProfile = ""
+	container.ProcessLabel = ""
+	container.seccomp = nil
+
+	var seccompOpt string
+	for _, opt := range hostConfig.SecurityOpt {
+		key, value, err := parseSecurityOpt(opt)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "apparmor":
+			container.AppArmorProfile = value
+		case "seccomp":
+			seccompOpt = value
+		}
+	}
+
+	if container.AppArmorProfile == "" && hostConfig.Privileged {
+		container.AppArmorProfile = "unconfined"
+	}
+	if profile := container.AppArmorProfile; profile != "" && profile != "unconfined" && !container.runtime.capabilities.AppArmor {
+		return fmt.Errorf("Impossible to use the AppArmor profile %s: AppArmor is not enabled on this host", profile)
+	}
+
+	switch {
+	case seccompOpt == "unconfined", seccompOpt == "" && hostConfig.Privileged:
+		container.SeccompProfile = "unconfined"
+	case seccompOpt == "":
+		if !container.runtime.capabilities.Seccomp {
+			container.SeccompProfile = "unconfined"
+			break
+		}
+		container.SeccompProfile = "default"
+		container.seccomp = defaultSeccompProfile(container.Capabilities)
+	default:
+		if !container.runtime.capabilities.Seccomp {
+			return fmt.Errorf("Impossible to use a seccomp profile: your kernel does not support seccomp")
+		}
+		profile, err := parseSeccompProfile([]byte(seccompOpt))
+		if err != nil {
+			return err
+		}
+		container.SeccompProfile = "custom"
+		container.seccomp = profile
+	}
+	return nil
+}
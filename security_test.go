@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"testing"
+)
+
+func TestParseSecurityOpt(t *testing.T) {
+	key, value, err := parseSecurityOpt("apparmor=docker-default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "apparmor" || value != "docker-default" {
+		t.Fatalf("Unexpected option: %s=%s", key, value)
+	}
+	for _, invalid := range []string{"apparmor", "apparmor=", "selinux=type:svirt_t"} {
+		if _, _, err := parseSecurityOpt(invalid); err == nil {
+			t.Errorf("%s should be an invalid security option", invalid)
+		}
+	}
+}
+
+func TestSetupSecurity(t *testing.T) {
+	container := &Container{runtime: &Runtime{capabilities: &Capabilities{Seccomp: true}}}
+
+	// The default profiles
+	if err := container.setupSecurity(&HostConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if container.AppArmorProfile != "" || container.SeccompProfile != "default" || container.seccomp == nil {
+		t.Fatalf("Expected the default profiles, got %q and %q", container.AppArmorProfile, container.SeccompProfile)
+	}
+
+	// Privileged containers are unconfined
+	if err := container.setupSecurity(&HostConfig{Privileged: true}); err != nil {
+		t.Fatal(err)
+	}
+	if container.AppArmorProfile != "unconfined" || container.SeccompProfile != "unconfined" || container.seccomp != nil {
+		t.Fatalf("Expected unconfined profiles, got %q and %q", container.AppArmorProfile, container.SeccompProfile)
+	}
+
+	// A custom seccomp profile
+	hostConfig := &HostConfig{SecurityOpt: []string{`seccomp={"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"name": "chmod", "action": "SCMP_ACT_ERRNO"}]}`}}
+	if err := container.setupSecurity(hostConfig); err != nil {
+		t.Fatal(err)
+	}
+	if container.SeccompProfile != "custom" || len(container.seccomp.Syscalls) != 1 {
+		t.Fatalf("Expected the custom profile, got %q", container.SeccompProfile)
+	}
+
+	// AppArmor profiles need AppArmor
+	if err := container.setupSecurity(&HostConfig{SecurityOpt: []string{"apparmor=docker-default"}}); err == nil {
+		t.Fatal("Selecting an AppArmor profile should fail when AppArmor is disabled")
+	}
+	container.runtime.capabilities.AppArmor = true
+	if err := container.setupSecurity(&HostConfig{SecurityOpt: []string{"apparmor=docker-default"}}); err != nil {
+		t.Fatal(err)
+	}
+	if container.AppArmorProfile != "docker-default" {
+		t.Fatalf("Expected docker-default, got %q", container.AppArmorProfile)
+	}
+}
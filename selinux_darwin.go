@@ -0,0 +1,10 @@
+package docker
+
+// There is no SELinux on darwin, so there is nothing to relabel
+func relabel(p string, shared bool, id string) error {
+	return nil
+}
+
+func selinuxProcessLabel(id string) string {
+	return ""
+}
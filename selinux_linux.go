@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// The type of the files the containers are allowed to use, and the one of
+// their processes
+const (
+	selinuxFileLabel   = "system_u:object_r:svirt_sandbox_file_t:s0"
+	selinuxProcessType = "system_u:system_r:svirt_lxc_net_t:s0"
+)
+
+// Directories which would break the host once relabeled
+var selinuxUnlabeled = []string{"/", "/bin", "/boot", "/dev", "/etc", "/home", "/lib", "/lib64", "/proc", "/root", "/run", "/sbin", "/sys", "/usr", "/var"}
+
+func selinuxEnabled() bool {
+	_, err := os.Stat("/sys/fs/selinux/enforce")
+	return err == nil
+}
+
+// relabel gives the files under `p` the SELinux label of the files of the
+// containers. Shared labels are usable by every container, private ones
+// get the categories of the container with the given ID. It does nothing on
+// hosts without SELinux.
+func relabel(p string, shared bool, id string) error {
+	for _, dir := range selinuxUnlabeled {
+		if path.Clean(p) == dir {
+			return fmt.Errorf("Impossible to relabel %s: it is a system directory", p)
+		}
+	}
+	if !selinuxEnabled() {
+		return nil
+	}
+	label := selinuxFileLabel
+	if !shared {
+		label += ":" + mcsCategories(id)
+	}
+	return filepath.Walk(p, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// setxattr follows symlinks, which are labeled by their targets
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		if err := syscall.Setxattr(p, "security.selinux", []byte(label), 0); err != nil {
+			return fmt.Errorf("Unable to relabel %s: %s", p, err)
+		}
+		return nil
+	})
+}
+
+// selinuxProcessLabel returns the label of the processes of the container
+// with the given ID, which gives them access to its private files. It is
+// empty on hosts without SELinux.
+func selinuxProcessLabel(id string) string {
+	if !selinuxEnabled() {
+		return ""
+	}
+	return selinuxProcessType + ":" + mcsCategories(id)
+}
+
+// mcsCategories returns a pair of distinct MCS categories for a container
+func mcsCategories(id string) string {
+	c1, _ := strconv.ParseUint(id[0:8], 16, 32)
+	c2, _ := strconv.ParseUint(id[8:16], 16, 32)
+	c1, c2 = c1%1024, c2%1024
+	if c1 == c2 {
+		c2 = (c2 + 1) % 1024
+	}
+	if c1 > c2 {
+		c1, c2 = c2, c1
+	}
+	return fmt.Sprintf("c%d,c%d", c1, c2)
+}
@@ -0,0 +1,28 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMCSCategories(t *testing.T) {
+	id := GenerateID()
+	categories := mcsCategories(id)
+	if categories != mcsCategories(id) {
+		t.Fatal("The categories of a container should not change")
+	}
+	if !strings.HasPrefix(categories, "c") || strings.Count(categories, ",c") != 1 {
+		t.Fatalf("Invalid categories: %s", categories)
+	}
+	if c := mcsCategories(strings.Repeat("0", 64)); c != "c0,c1" {
+		t.Fatalf("Expected distinct categories, got %s", c)
+	}
+}
+
+func TestRelabelSystemDirectories(t *testing.T) {
+	for _, dir := range []string{"/", "/usr", "/etc/"} {
+		if err := relabel(dir, true, GenerateID()); err == nil {
+			t.Fatalf("Relabeling %s should be refused", dir)
+		}
+	}
+}
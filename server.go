@@ -56,6 +56,27 @@ func (srv *Server) ContainerExport(name string, out io.Writer) error {
 	return fmt.Errorf("No such container: %s", name)
 }
 
+func (srv *Server) ContainerCopy(name, resource string, out io.Writer) error {
+	if container := srv.runtime.Get(name); container != nil {
+		data, err := container.Copy(resource)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, data); err != nil {
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("No such container: %s", name)
+}
+
+func (srv *Server) ContainerExtract(name, dst string, in io.Reader) error {
+	if container := srv.runtime.Get(name); container != nil {
+		return container.InjectArchive(in, dst)
+	}
+	return fmt.Errorf("No such container: %s", name)
+}
+
 func (srv *Server) ImagesSearch(term string) ([]APISearch, error) {
 	r, err := registry.NewRegistry(srv.runtime.root, nil)
 	if err != nil {
@@ -208,13 +229,19 @@ func (srv *Server) DockerInfo() *APIInfo {
 		imgcount = len(images)
 	}
 	return &APIInfo{
-		Containers:  len(srv.runtime.List()),
-		Images:      imgcount,
-		MemoryLimit: srv.runtime.capabilities.MemoryLimit,
-		SwapLimit:   srv.runtime.capabilities.SwapLimit,
-		Debug:       os.Getenv("DEBUG") != "",
-		NFd:         utils.GetTotalUsedFds(),
-		NGoroutines: runtime.NumGoroutine(),
+		Containers:      len(srv.runtime.List()),
+		Images:          imgcount,
+		ExecutionDriver: srv.runtime.execDriver.Name(),
+		MemoryLimit:     srv.runtime.capabilities.MemoryLimit,
+		SwapLimit:       srv.runtime.capabilities.SwapLimit,
+		CpuCfsQuota:     srv.runtime.capabilities.CpuCfsQuota,
+		Cpuset:          srv.runtime.capabilities.Cpuset,
+		BlkioWeight:     srv.runtime.capabilities.BlkioWeight,
+		BlkioThrottle:   srv.runtime.capabilities.BlkioThrottle,
+		PidsLimit:       srv.runtime.capabilities.PidsLimit,
+		Debug:           os.Getenv("DEBUG") != "",
+		NFd:             utils.GetTotalUsedFds(),
+		NGoroutines:     runtime.NumGoroutine(),
 	}
 }
 
@@ -828,31 +855,24 @@ func (srv *Server) ContainerDestroy(name string, removeVolume bool) error {
 		if container.State.Running {
 			return fmt.Errorf("Impossible to remove a running container, please stop it first")
 		}
-		volumes := make(map[string]struct{})
-		// Store all the deleted containers volumes
-		for _, volumeId := range container.Volumes {
-			volumes[volumeId] = struct{}{}
+		// Store the anonymous volumes of the container, named ones are kept
+		volumes := []*Volume{}
+		for _, name := range container.VolumeNames {
+			if vol, err := srv.runtime.volumes.Get(name); err == nil && vol.Anonymous {
+				volumes = append(volumes, vol)
+			}
 		}
 		if err := srv.runtime.Destroy(container); err != nil {
 			return fmt.Errorf("Error destroying container %s: %s", name, err)
 		}
 
 		if removeVolume {
-			// Retrieve all volumes from all remaining containers
-			usedVolumes := make(map[string]*Container)
-			for _, container := range srv.runtime.List() {
-				for _, containerVolumeId := range container.Volumes {
-					usedVolumes[containerVolumeId] = container
-				}
-			}
-
-			for volumeId := range volumes {
-				// If the requested volu
-				if c, exists := usedVolumes[volumeId]; exists {
-					log.Printf("The volume %s is used by the container %s. Impossible to remove it. Skipping.\n", volumeId, c.ID)
+			for _, vol := range volumes {
+				if users := srv.runtime.volumes.Users(vol.Name); len(users) > 0 {
+					log.Printf("The volume %s is used by the container %s. Impossible to remove it. Skipping.\n", vol.Name, users[0])
 					continue
 				}
-				if err := srv.runtime.volumes.Delete(volumeId); err != nil {
+				if err := srv.runtime.volumes.Remove(vol.Name); err != nil {
 					return err
 				}
 			}
@@ -1010,6 +1030,17 @@ func (srv *Server) ContainerStart(name string, hostConfig *HostConfig) error {
 	return nil
 }
 
+func (srv *Server) ContainerUpdate(name string, update *UpdateConfig) error {
+	if container := srv.runtime.Get(name); container != nil {
+		if err := container.Update(update); err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("No such container: %s", name)
+	}
+	return nil
+}
+
 func (srv *Server) ContainerStop(name string, t int) error {
 	if container := srv.runtime.Get(name); container != nil {
 		if err := container.Stop(t); err != nil {
@@ -1107,6 +1138,59 @@ func (srv *Server) ContainerInspect(name string) (*Container, error) {
 	return nil, fmt.Errorf("No such container: %s", name)
 }
 
+func (srv *Server) apiVolume(vol *Volume) *APIVolume {
+	driver := vol.Driver
+	if driver == "" {
+		driver = "local"
+	}
+	return &APIVolume{
+		Name:       vol.Name,
+		Driver:     driver,
+		Mountpoint: vol.Mountpoint,
+		Created:    vol.Created.Unix(),
+		UsedBy:     srv.runtime.volumes.Users(vol.Name),
+	}
+}
+
+func (srv *Server) Volumes() []APIVolume {
+	outs := []APIVolume{}
+	for _, vol := range srv.runtime.volumes.List() {
+		outs = append(outs, *srv.apiVolume(vol))
+	}
+	return outs
+}
+
+func (srv *Server) VolumeCreate(name, driver string, opts map[string]string) (*APIVolume, error) {
+	if name == "" {
+		return nil, fmt.Errorf("Bad parameter: the volume needs a name")
+	}
+	vol, err := srv.runtime.volumes.Create(name, driver, opts)
+	if err != nil {
+		return nil, err
+	}
+	return srv.apiVolume(vol), nil
+}
+
+func (srv *Server) VolumeInspect(name string) (*APIVolume, error) {
+	vol, err := srv.runtime.volumes.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return srv.apiVolume(vol), nil
+}
+
+func (srv *Server) VolumeRemove(name string) error {
+	return srv.runtime.volumes.Remove(name)
+}
+
+func (srv *Server) VolumesPrune() (*APIVolumesPrune, error) {
+	deleted, reclaimed, err := srv.runtime.volumes.Prune()
+	if err != nil {
+		return nil, err
+	}
+	return &APIVolumesPrune{VolumesDeleted: deleted, SpaceReclaimed: reclaimed}, nil
+}
+
 func (srv *Server) ImageInspect(name string) (*Image, error) {
 	if image, err := srv.runtime.repositories.LookupImage(name); err == nil && image != nil {
 		return image, nil
@@ -9,11 +9,15 @@ import (
 
 type State struct {
 	sync.Mutex
-	Running   bool
-	Pid       int
-	ExitCode  int
-	StartedAt time.Time
-	Ghost     bool
+	Running    bool
+	Pid        int
+	ExitCode   int
+	Signal     int  // Signal which terminated the process, 0 if it exited normally
+	OOMKilled  bool // The process was killed by the kernel OOM killer
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Ghost      bool
 }
 
 // String returns a human-readable description of the state
@@ -24,13 +28,25 @@ func (s *State) String() string {
 		}
 		return fmt.Sprintf("Up %s", utils.HumanDuration(time.Now().Sub(s.StartedAt)))
 	}
-	return fmt.Sprintf("Exit %d", s.ExitCode)
+	if s.Error != "" {
+		return fmt.Sprintf("Error: %s", s.Error)
+	}
+	status := fmt.Sprintf("Exit %d", s.ExitCode)
+	if s.OOMKilled {
+		status += " (OOM killed)"
+	} else if s.Signal != 0 {
+		status += fmt.Sprintf(" (signal %d)", s.Signal)
+	}
+	return status
 }
 
 func (s *State) setRunning(pid int) {
 	s.Running = true
 	s.Ghost = false
 	s.ExitCode = 0
+	s.Signal = 0
+	s.OOMKilled = false
+	s.Error = ""
 	s.Pid = pid
 	s.StartedAt = time.Now()
 }
@@ -39,4 +55,10 @@ func (s *State) setStopped(exitCode int) {
 	s.Running = false
 	s.Pid = 0
 	s.ExitCode = exitCode
+	s.FinishedAt = time.Now()
+}
+
+// setError records the reason why the container could not be started
+func (s *State) setError(err error) {
+	s.Error = err.Error()
 }
@@ -1,12 +1,16 @@
 package docker
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"io"
 	"log"
 	"os"
 	"os/exec"
-	"os/user"
+	"os/signal"
+	"path"
 	"strconv"
 	"strings"
 	"syscall"
@@ -22,32 +26,230 @@ func setupNetworking(gw string) {
 	}
 }
 
+// Move to the working directory, creating it if necessary
+func setupWorkingDirectory(workdir string) {
+	if workdir == "" {
+		return
+	}
+	if err := os.MkdirAll(workdir, 0755); err != nil {
+		log.Fatalf("Unable to create working directory %v: %v", workdir, err)
+	}
+	if err := syscall.Chdir(workdir); err != nil {
+		log.Fatalf("Unable to change to working directory %v: %v", workdir, err)
+	}
+}
+
+// Apply the resource limits, given as name=soft:hard
+func setupUlimits(ulimits ListOpts) {
+	for _, spec := range ulimits {
+		u, err := ParseUlimit(spec)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		rlimit := &syscall.Rlimit{Cur: uint64(u.Soft), Max: uint64(u.Hard)}
+		if err := syscall.Setrlimit(ulimitResources[u.Name], rlimit); err != nil {
+			log.Fatalf("Unable to set the %s ulimit: %v", u.Name, err)
+		}
+	}
+}
+
+// An execUser holds the credentials a containerized process runs with,
+// as resolved from the container's /etc/passwd and /etc/group
+type execUser struct {
+	Uid  int
+	Gid  int
+	Sgid []int
+	Home string
+}
+
+type passwdEntry struct {
+	Name string
+	Uid  int
+	Gid  int
+	Home string
+}
+
+type groupEntry struct {
+	Name    string
+	Gid     int
+	Members []string
+}
+
+// parsePasswd reads entries in the /etc/passwd format. Malformed lines are skipped.
+func parsePasswd(r io.Reader) ([]passwdEntry, error) {
+	var entries []passwdEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// name:password:uid:gid:gecos:home:shell
+		parts := strings.Split(line, ":")
+		if len(parts) < 6 {
+			continue
+		}
+		uid, err := strconv.Atoi(parts[2])
+		if err != nil {
+			continue
+		}
+		gid, err := strconv.Atoi(parts[3])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, passwdEntry{Name: parts[0], Uid: uid, Gid: gid, Home: parts[5]})
+	}
+	return entries, scanner.Err()
+}
+
+// parseGroup reads entries in the /etc/group format. Malformed lines are skipped.
+func parseGroup(r io.Reader) ([]groupEntry, error) {
+	var entries []groupEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// name:password:gid:member1,member2
+		parts := strings.Split(line, ":")
+		if len(parts) < 3 {
+			continue
+		}
+		gid, err := strconv.Atoi(parts[2])
+		if err != nil {
+			continue
+		}
+		entry := groupEntry{Name: parts[0], Gid: gid}
+		if len(parts) > 3 && parts[3] != "" {
+			entry.Members = strings.Split(parts[3], ",")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// getExecUser resolves a user specification of the form user[:group] against
+// the given passwd and group databases. Both user and group can be either a
+// name or a numeric id. Numeric ids which are not present in the databases are
+// used as is. Either reader may be nil if the corresponding file is missing.
+func getExecUser(spec string, passwd, group io.Reader) (*execUser, error) {
+	userSpec, groupSpec := spec, ""
+	if parts := strings.SplitN(spec, ":", 2); len(parts) == 2 {
+		userSpec, groupSpec = parts[0], parts[1]
+	}
+	if userSpec == "" {
+		return nil, fmt.Errorf("Invalid user specification: %s", spec)
+	}
+
+	var users []passwdEntry
+	if passwd != nil {
+		entries, err := parsePasswd(passwd)
+		if err != nil {
+			return nil, err
+		}
+		users = entries
+	}
+	var groups []groupEntry
+	if group != nil {
+		entries, err := parseGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		groups = entries
+	}
+
+	execUser := &execUser{Home: "/"}
+	userName := ""
+	uid, uidErr := strconv.Atoi(userSpec)
+	found := false
+	for _, u := range users {
+		if (uidErr == nil && u.Uid == uid) || (uidErr != nil && u.Name == userSpec) {
+			execUser.Uid, execUser.Gid, execUser.Home = u.Uid, u.Gid, u.Home
+			userName = u.Name
+			found = true
+			break
+		}
+	}
+	if !found {
+		if uidErr != nil {
+			return nil, fmt.Errorf("Unable to find user %s", userSpec)
+		}
+		execUser.Uid = uid
+	}
+
+	if groupSpec != "" {
+		gid, gidErr := strconv.Atoi(groupSpec)
+		found := false
+		for _, g := range groups {
+			if (gidErr == nil && g.Gid == gid) || (gidErr != nil && g.Name == groupSpec) {
+				execUser.Gid = g.Gid
+				found = true
+				break
+			}
+		}
+		if !found {
+			if gidErr != nil {
+				return nil, fmt.Errorf("Unable to find group %s", groupSpec)
+			}
+			execUser.Gid = gid
+		}
+	}
+
+	// Supplementary groups are the ones listing the user as a member
+	execUser.Sgid = []int{execUser.Gid}
+	if userName != "" {
+		for _, g := range groups {
+			if g.Gid == execUser.Gid {
+				continue
+			}
+			for _, member := range g.Members {
+				if member == userName {
+					execUser.Sgid = append(execUser.Sgid, g.Gid)
+					break
+				}
+			}
+		}
+	}
+	return execUser, nil
+}
+
+// lookupUser resolves a user specification against the container's
+// /etc/passwd and /etc/group
+func lookupUser(spec string) (*execUser, error) {
+	var passwd, group io.Reader
+	if f, err := os.Open("/etc/passwd"); err == nil {
+		defer f.Close()
+		passwd = f
+	}
+	if f, err := os.Open("/etc/group"); err == nil {
+		defer f.Close()
+		group = f
+	}
+	return getExecUser(spec, passwd, group)
+}
+
 // Takes care of dropping privileges to the desired user
 func changeUser(u string) {
 	if u == "" {
 		return
 	}
-	userent, err := user.LookupId(u)
-	if err != nil {
-		userent, err = user.Lookup(u)
-	}
+	execUser, err := lookupUser(u)
 	if err != nil {
 		log.Fatalf("Unable to find user %v: %v", u, err)
 	}
 
-	uid, err := strconv.Atoi(userent.Uid)
-	if err != nil {
-		log.Fatalf("Invalid uid: %v", userent.Uid)
-	}
-	gid, err := strconv.Atoi(userent.Gid)
-	if err != nil {
-		log.Fatalf("Invalid gid: %v", userent.Gid)
+	if os.Getenv("HOME") == "" {
+		os.Setenv("HOME", execUser.Home)
 	}
 
-	if err := syscall.Setgid(gid); err != nil {
+	if err := syscall.Setgroups(execUser.Sgid); err != nil {
+		log.Fatalf("setgroups failed: %v", err)
+	}
+	if err := syscall.Setgid(execUser.Gid); err != nil {
 		log.Fatalf("setgid failed: %v", err)
 	}
-	if err := syscall.Setuid(uid); err != nil {
+	if err := syscall.Setuid(execUser.Uid); err != nil {
 		log.Fatalf("setuid failed: %v", err)
 	}
 }
@@ -76,6 +278,92 @@ func executeProgram(name string, args []string) {
 	}
 }
 
+// Run the program as a child of docker-init, which stays around as PID 1 of
+// the container: signals are forwarded to the child, orphaned processes are
+// reaped, and docker-init exits with the status of the child.
+func runInit(name string, args []string, u string) {
+	program, err := exec.LookPath(name)
+	if err != nil {
+		log.Printf("Unable to locate %v", name)
+		os.Exit(127)
+	}
+
+	sysProcAttr := &syscall.SysProcAttr{}
+	if u != "" {
+		execUser, err := lookupUser(u)
+		if err != nil {
+			log.Fatalf("Unable to find user %v: %v", u, err)
+		}
+		if os.Getenv("HOME") == "" {
+			os.Setenv("HOME", execUser.Home)
+		}
+		groups := make([]uint32, len(execUser.Sgid))
+		for i, gid := range execUser.Sgid {
+			groups[i] = uint32(gid)
+		}
+		sysProcAttr.Credential = &syscall.Credential{
+			Uid:    uint32(execUser.Uid),
+			Gid:    uint32(execUser.Gid),
+			Groups: groups,
+		}
+	}
+	if os.Getenv("HOME") == "" {
+		os.Setenv("HOME", "/")
+	}
+
+	// Catch the signals before forking, so that no SIGCHLD gets lost. The
+	// runtime raises signals of its own, e.g. SIGURG: only the ones sent to
+	// the container are forwarded.
+	sigs := make(chan os.Signal, 32)
+	signal.Notify(sigs, syscall.SIGCHLD, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT,
+		syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH, syscall.SIGCONT)
+
+	pid, err := syscall.ForkExec(program, args, &syscall.ProcAttr{
+		Env:   os.Environ(),
+		Files: []uintptr{0, 1, 2},
+		Sys:   sysProcAttr,
+	})
+	if err != nil {
+		log.Printf("Unable to start %v: %v", name, err)
+		os.Exit(127)
+	}
+
+	// Let the daemon talk to us if the control directory was mounted
+	srv := newInitServer(pid)
+	if _, err := os.Stat(controlDir); err == nil {
+		go srv.listen(path.Join(controlDir, controlSocket))
+	}
+
+	for sig := range sigs {
+		if sig != syscall.SIGCHLD {
+			if err := syscall.Kill(pid, sig.(syscall.Signal)); err != nil {
+				utils.Debugf("Unable to forward %v: %v", sig, err)
+			}
+			continue
+		}
+		// Several children may have exited for a single SIGCHLD
+		for {
+			var status syscall.WaitStatus
+			wpid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+			if err != nil || wpid <= 0 {
+				break
+			}
+			if wpid != pid {
+				continue
+			}
+			// The exit code can't tell a signal from an exit with 128+n: the
+			// daemon gets the signal from the control socket
+			exitCode, signal := status.ExitStatus(), 0
+			if status.Signaled() {
+				signal = int(status.Signal())
+				exitCode = 128 + signal
+			}
+			srv.finish(exitCode, signal)
+			os.Exit(exitCode)
+		}
+	}
+}
+
 // Sys Init code
 // This code is run INSIDE the container and is responsible for setting
 // up the environment before running the actual process
@@ -86,14 +374,33 @@ func SysInit() {
 	}
 	var u = flag.String("u", "", "username or uid")
 	var gw = flag.String("g", "", "gateway address")
+	var workdir = flag.String("w", "", "workdir")
+	var initMode = flag.Bool("init", false, "stay as PID 1, forward signals and reap processes")
 
 	var flEnv ListOpts
 	flag.Var(&flEnv, "e", "Set environment variables")
 
+	var flUlimits ListOpts
+	flag.Var(&flUlimits, "ulimit", "Set resource limits")
+
 	flag.Parse()
 
+	// The native execution driver leaves the setup of the container to us
+	if IsNativeInit() {
+		setupNativeContainer()
+	}
+
 	cleanupEnv(flEnv)
 	setupNetworking(*gw)
+	setupWorkingDirectory(*workdir)
+	setupUlimits(flUlimits)
+	if *initMode {
+		runInit(flag.Arg(0), flag.Args(), *u)
+		return
+	}
 	changeUser(*u)
+	if os.Getenv("HOME") == "" {
+		os.Setenv("HOME", "/")
+	}
 	executeProgram(flag.Arg(0), flag.Args())
 }
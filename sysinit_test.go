@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPasswd = `root:x:0:0:root:/root:/bin/bash
+daemon:x:1:1:daemon:/usr/sbin:/bin/sh
+# a comment
+broken line
+gordon:x:1000:1000:Gordon:/home/gordon:/bin/sh
+`
+
+const testGroup = `root:x:0:
+daemon:x:1:
+adm:x:4:gordon,daemon
+docker:x:999:gordon
+gordon:x:1000:
+`
+
+func TestGetExecUser(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected execUser
+	}{
+		{"root", execUser{Uid: 0, Gid: 0, Sgid: []int{0}, Home: "/root"}},
+		{"0", execUser{Uid: 0, Gid: 0, Sgid: []int{0}, Home: "/root"}},
+		{"daemon", execUser{Uid: 1, Gid: 1, Sgid: []int{1, 4}, Home: "/usr/sbin"}},
+		{"gordon", execUser{Uid: 1000, Gid: 1000, Sgid: []int{1000, 4, 999}, Home: "/home/gordon"}},
+		{"gordon:docker", execUser{Uid: 1000, Gid: 999, Sgid: []int{999, 4}, Home: "/home/gordon"}},
+		{"1000:0", execUser{Uid: 1000, Gid: 0, Sgid: []int{0, 4, 999}, Home: "/home/gordon"}},
+		{"4242:4243", execUser{Uid: 4242, Gid: 4243, Sgid: []int{4243}, Home: "/"}},
+	}
+	for _, test := range tests {
+		u, err := getExecUser(test.spec, strings.NewReader(testPasswd), strings.NewReader(testGroup))
+		if err != nil {
+			t.Errorf("%s: %s", test.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(*u, test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", test.spec, test.expected, *u)
+		}
+	}
+
+	for _, spec := range []string{"", ":0", "nobody", "root:nogroup"} {
+		if _, err := getExecUser(spec, strings.NewReader(testPasswd), strings.NewReader(testGroup)); err == nil {
+			t.Errorf("%q should fail to resolve", spec)
+		}
+	}
+
+	// Without passwd and group files, only numeric ids can be used
+	if u, err := getExecUser("42:43", nil, nil); err != nil {
+		t.Fatal(err)
+	} else if u.Uid != 42 || u.Gid != 43 {
+		t.Errorf("Expected 42:43, got %d:%d", u.Uid, u.Gid)
+	}
+	if _, err := getExecUser("root", nil, nil); err == nil {
+		t.Error("Resolving a user name without a passwd file should fail")
+	}
+}
@@ -0,0 +1,64 @@
+package docker
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultShmSize is the size of /dev/shm when -shm-size is not given
+const defaultShmSize = 64 * 1024 * 1024
+
+// tmpfsMount is a tmpfs filesystem mounted in a container
+type tmpfsMount struct {
+	Target  string
+	Options string // Options of mount(8), e.g. nosuid,size=65536k
+}
+
+// parseTmpfs parses a tmpfs specification of the form path[:options]
+func parseTmpfs(spec string) (string, string, error) {
+	target, options := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		target, options = spec[:i], spec[i+1:]
+	}
+	if !path.IsAbs(target) || path.Clean(target) == "/" {
+		return "", "", fmt.Errorf("Invalid tmpfs destination: %s. It needs to be an absolute path other than /.", target)
+	}
+	return path.Clean(target), options, nil
+}
+
+// tmpfsMounts returns the tmpfs filesystems of the container, parents first.
+// Every container gets a /dev/shm of its own, unless it is given explicitly.
+func tmpfsMounts(container *Container) []tmpfsMount {
+	hostConfig := container.hostConfig
+	if hostConfig == nil {
+		hostConfig = &HostConfig{}
+	}
+	shmSize := hostConfig.ShmSize
+	if shmSize <= 0 {
+		shmSize = defaultShmSize
+	}
+	options := map[string]string{
+		"/dev/shm": "nosuid,nodev,noexec,mode=1777,size=" + strconv.FormatInt(shmSize, 10),
+	}
+	for target, opts := range hostConfig.Tmpfs {
+		target = path.Clean(target)
+		options[target] = "nosuid,nodev,noexec"
+		if opts != "" {
+			options[target] += "," + opts
+		}
+	}
+
+	targets := []string{}
+	for target := range options {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	mounts := []tmpfsMount{}
+	for _, target := range targets {
+		mounts = append(mounts, tmpfsMount{target, options[target]})
+	}
+	return mounts
+}
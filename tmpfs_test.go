@@ -0,0 +1,56 @@
+package docker
+
+import (
+	"testing"
+)
+
+func TestParseTmpfs(t *testing.T) {
+	target, options, err := parseTmpfs("/run/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "/run" || options != "" {
+		t.Fatalf("Unexpected tmpfs: %s %s", target, options)
+	}
+
+	target, options, err = parseTmpfs("/tmp:size=64m,exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "/tmp" || options != "size=64m,exec" {
+		t.Fatalf("Unexpected tmpfs: %s %s", target, options)
+	}
+
+	for _, spec := range []string{"", "tmp", "/", "/..:size=1m"} {
+		if _, _, err := parseTmpfs(spec); err == nil {
+			t.Errorf("%s should be refused", spec)
+		}
+	}
+}
+
+func TestTmpfsMounts(t *testing.T) {
+	// Every container gets a /dev/shm
+	mounts := tmpfsMounts(&Container{})
+	if len(mounts) != 1 || mounts[0].Target != "/dev/shm" || mounts[0].Options != "nosuid,nodev,noexec,mode=1777,size=67108864" {
+		t.Fatalf("Unexpected tmpfs mounts: %v", mounts)
+	}
+
+	container := &Container{hostConfig: &HostConfig{
+		ShmSize: 1024,
+		Tmpfs:   map[string]string{"/var/run/": "size=1m", "/var": ""},
+	}}
+	mounts = tmpfsMounts(container)
+	expected := []tmpfsMount{
+		{"/dev/shm", "nosuid,nodev,noexec,mode=1777,size=1024"},
+		{"/var", "nosuid,nodev,noexec"},
+		{"/var/run", "nosuid,nodev,noexec,size=1m"},
+	}
+	if len(mounts) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, mounts)
+	}
+	for i := range expected {
+		if mounts[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], mounts[i])
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package docker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultUlimits are applied to every container, unless HostConfig.Ulimits
+// sets the same resource. They are set with docker -d -default-ulimit.
+var DefaultUlimits []*Ulimit
+
+// An Ulimit is a resource limit applied with setrlimit by docker-init
+type Ulimit struct {
+	Name string
+	Soft int64
+	Hard int64
+}
+
+// ulimitResources maps the names of the resources to their RLIMIT_* number on Linux
+var ulimitResources = map[string]int{
+	"cpu":        0,
+	"fsize":      1,
+	"data":       2,
+	"stack":      3,
+	"core":       4,
+	"rss":        5,
+	"nproc":      6,
+	"nofile":     7,
+	"memlock":    8,
+	"as":         9,
+	"locks":      10,
+	"sigpending": 11,
+	"msgqueue":   12,
+	"nice":       13,
+	"rtprio":     14,
+}
+
+// ParseUlimit parses an ulimit of the form name=soft[:hard]. The hard limit
+// defaults to the soft one.
+func ParseUlimit(spec string) (*Ulimit, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid ulimit: %s. It needs to be of the form name=soft[:hard].", spec)
+	}
+	if _, exists := ulimitResources[parts[0]]; !exists {
+		return nil, fmt.Errorf("Unknown ulimit: %s", parts[0])
+	}
+	limits := strings.SplitN(parts[1], ":", 2)
+	soft, err := strconv.ParseInt(limits[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ulimit value: %s", limits[0])
+	}
+	hard := soft
+	if len(limits) == 2 {
+		if hard, err = strconv.ParseInt(limits[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid ulimit value: %s", limits[1])
+		}
+	}
+	if soft < 0 || hard < 0 {
+		return nil, fmt.Errorf("Invalid ulimit: %s. The limits can't be negative.", spec)
+	}
+	if soft > hard {
+		return nil, fmt.Errorf("Invalid ulimit: %s. The soft limit can't be greater than the hard limit.", spec)
+	}
+	return &Ulimit{Name: parts[0], Soft: soft, Hard: hard}, nil
+}
+
+func (u *Ulimit) String() string {
+	return fmt.Sprintf("%s=%d:%d", u.Name, u.Soft, u.Hard)
+}
+
+// mergeUlimits returns the default ulimits overridden by the ones of the container
+func mergeUlimits(defaults, ulimits []*Ulimit) []*Ulimit {
+	merged := []*Ulimit{}
+	overridden := make(map[string]bool)
+	for _, u := range ulimits {
+		overridden[u.Name] = true
+	}
+	for _, u := range defaults {
+		if !overridden[u.Name] {
+			merged = append(merged, u)
+		}
+	}
+	return append(merged, ulimits...)
+}
@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"testing"
+)
+
+func TestParseUlimit(t *testing.T) {
+	u, err := ParseUlimit("nofile=1024:4096")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "nofile" || u.Soft != 1024 || u.Hard != 4096 {
+		t.Fatalf("Unexpected ulimit: %v", u)
+	}
+	if u.String() != "nofile=1024:4096" {
+		t.Fatalf("Unexpected ulimit: %s", u)
+	}
+
+	// The hard limit defaults to the soft one
+	u, err = ParseUlimit("core=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Soft != 0 || u.Hard != 0 {
+		t.Fatalf("Unexpected ulimit: %v", u)
+	}
+
+	for _, spec := range []string{"nofile", "files=10", "nofile=a", "nofile=10:b", "nofile=20:10", "nproc=-1"} {
+		if _, err := ParseUlimit(spec); err == nil {
+			t.Errorf("%s should be refused", spec)
+		}
+	}
+}
+
+func TestMergeUlimits(t *testing.T) {
+	defaults := []*Ulimit{{"nofile", 1024, 1024}, {"core", 0, 0}}
+	merged := mergeUlimits(defaults, []*Ulimit{{"nofile", 65536, 65536}})
+	if len(merged) != 2 {
+		t.Fatalf("Expected 2 ulimits, got %v", merged)
+	}
+	if merged[0].Name != "core" || merged[1].Name != "nofile" || merged[1].Soft != 65536 {
+		t.Fatalf("The ulimits of the container should override the defaults, got %v %v", merged[0], merged[1])
+	}
+}
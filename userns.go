@@ -0,0 +1,383 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// UsernsRemap runs the containers in user namespaces, with their ids mapped
+// to the subordinate ids of a user of the host. It is set with
+// docker -d -userns-remap, to default (the dockremap user) or user[:group].
+var UsernsRemap string
+
+const defaultRemapUser = "dockremap"
+
+// The ids of the host which have no container counterpart appear as nobody
+const overflowID = 65534
+
+// An idMap maps a range of ids of the containers to ids of the host
+type idMap struct {
+	ContainerID int
+	HostID      int
+	Size        int
+}
+
+// idMappings holds the uid and gid ranges of remapped containers. Layers
+// and volumes are stored with the ids of the host, archives use the ids of
+// the containers. A nil *idMappings means no remapping at all.
+type idMappings struct {
+	Uids []idMap
+	Gids []idMap
+}
+
+// parseSubIDs reads the ranges of one of the names in a file in the
+// /etc/subuid format (name:start:count). Successive ranges are mapped to
+// successive container ids, starting from 0.
+func parseSubIDs(r io.Reader, names ...string) ([]idMap, error) {
+	maps := []idMap{}
+	next := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 {
+			continue
+		}
+		matches := false
+		for _, name := range names {
+			if parts[0] == name {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		start, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid subordinate id range: %s", line)
+		}
+		size, err := strconv.Atoi(parts[2])
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("Invalid subordinate id range: %s", line)
+		}
+		maps = append(maps, idMap{ContainerID: next, HostID: start, Size: size})
+		next += size
+	}
+	return maps, scanner.Err()
+}
+
+// loadIDMappings reads the subordinate ids of the user and group given to
+// -userns-remap from /etc/subuid and /etc/subgid
+func loadIDMappings(spec string) (*idMappings, error) {
+	if spec == "default" {
+		spec = defaultRemapUser
+	}
+	userName, groupName := spec, spec
+	if parts := strings.SplitN(spec, ":", 2); len(parts) == 2 {
+		userName, groupName = parts[0], parts[1]
+	}
+
+	f, err := os.Open("/etc/passwd")
+	if err != nil {
+		return nil, err
+	}
+	users, err := parsePasswd(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	uidNames := []string{userName}
+	for _, u := range users {
+		if u.Name == userName || strconv.Itoa(u.Uid) == userName {
+			uidNames = []string{u.Name, strconv.Itoa(u.Uid)}
+			break
+		}
+	}
+	gidNames := []string{groupName}
+	if f, err := os.Open("/etc/group"); err == nil {
+		groups, err := parseGroup(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			if g.Name == groupName || strconv.Itoa(g.Gid) == groupName {
+				gidNames = []string{g.Name, strconv.Itoa(g.Gid)}
+				break
+			}
+		}
+	}
+
+	mappings := &idMappings{}
+	for _, subids := range []struct {
+		file  string
+		names []string
+		maps  *[]idMap
+	}{
+		{"/etc/subuid", uidNames, &mappings.Uids},
+		{"/etc/subgid", gidNames, &mappings.Gids},
+	} {
+		f, err := os.Open(subids.file)
+		if err != nil {
+			return nil, err
+		}
+		maps, err := parseSubIDs(f, subids.names...)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(maps) == 0 {
+			return nil, fmt.Errorf("No subordinate ids for %s in %s", subids.names[0], subids.file)
+		}
+		*subids.maps = maps
+	}
+	return mappings, nil
+}
+
+// mapID translates an id of a container to the host or back
+func mapID(maps []idMap, id int, toHost bool) (int, error) {
+	for _, m := range maps {
+		from, to := m.HostID, m.ContainerID
+		if toHost {
+			from, to = m.ContainerID, m.HostID
+		}
+		if id >= from && id < from+m.Size {
+			return to + id - from, nil
+		}
+	}
+	if !toHost {
+		return overflowID, nil
+	}
+	return -1, fmt.Errorf("Impossible to map id %d: it is outside of the remapped range", id)
+}
+
+func (m *idMappings) mapIDs(uid, gid int, toHost bool) (int, int, error) {
+	uid, err := mapID(m.Uids, uid, toHost)
+	if err != nil {
+		return -1, -1, err
+	}
+	gid, err = mapID(m.Gids, gid, toHost)
+	if err != nil {
+		return -1, -1, err
+	}
+	return uid, gid, nil
+}
+
+// RootPair returns the uid and the gid of the host which are root in
+// the containers
+func (m *idMappings) RootPair() (int, int) {
+	uid, gid, _ := m.mapIDs(0, 0, true)
+	return uid, gid
+}
+
+// chownRoot gives the given paths to the root of the containers
+func (m *idMappings) chownRoot(paths ...string) error {
+	if m == nil {
+		return nil
+	}
+	uid, gid := m.RootPair()
+	for _, p := range paths {
+		if err := os.Lchown(p, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shiftTree changes the owners of everything under root from the ids of
+// the containers to the ids of the host
+func (m *idMappings) shiftTree(root string) error {
+	// The links of an inode after the first one already have its new owner
+	type inode struct {
+		dev, ino uint64
+	}
+	shifted := make(map[inode]bool)
+	return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		stat, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("Unable to read the owner of %s", p)
+		}
+		if stat.Nlink > 1 && !fi.IsDir() {
+			key := inode{uint64(stat.Dev), uint64(stat.Ino)}
+			if shifted[key] {
+				return nil
+			}
+			shifted[key] = true
+		}
+		uid, gid, err := m.mapIDs(int(stat.Uid), int(stat.Gid), true)
+		if err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+		if err := os.Lchown(p, uid, gid); err != nil {
+			return err
+		}
+		// chown clears the setuid and setgid bits
+		if fi.Mode()&os.ModeSymlink == 0 && fi.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 {
+			return os.Chmod(p, fi.Mode())
+		}
+		return nil
+	})
+}
+
+// remapArchive rewrites the owners of the files of an uncompressed tar
+// archive, to the host or to the containers
+func (m *idMappings) remapArchive(archive io.Reader, toHost bool) io.Reader {
+	pipeR, pipeW := io.Pipe()
+	go func() {
+		tr := tar.NewReader(archive)
+		tw := tar.NewWriter(pipeW)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pipeW.CloseWithError(err)
+				return
+			}
+			if hdr.Uid, hdr.Gid, err = m.mapIDs(hdr.Uid, hdr.Gid, toHost); err != nil {
+				pipeW.CloseWithError(fmt.Errorf("%s: %s", hdr.Name, err))
+				return
+			}
+			hdr.Uname, hdr.Gname = "", ""
+			if err := tw.WriteHeader(hdr); err != nil {
+				pipeW.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				pipeW.CloseWithError(err)
+				return
+			}
+		}
+		pipeW.CloseWithError(tw.Close())
+	}()
+	return pipeR
+}
+
+// TarFilter is the TarFilter of archive.go, with the owners translated to
+// the ids of the containers
+func (m *idMappings) TarFilter(path string, compression Compression, filter []string) (Archive, error) {
+	if m == nil {
+		return TarFilter(path, compression, filter)
+	}
+	archive, err := TarFilter(path, Uncompressed, filter)
+	if err != nil {
+		return nil, err
+	}
+	return CompressStream(m.remapArchive(archive, false), compression)
+}
+
+// TarExclude is the TarExclude of archive.go, with the owners translated to
+// the ids of the containers
+func (m *idMappings) TarExclude(path string, compression Compression, exclude []string) (Archive, error) {
+	if m == nil {
+		return TarExclude(path, compression, exclude)
+	}
+	archive, err := TarExclude(path, Uncompressed, exclude)
+	if err != nil {
+		return nil, err
+	}
+	return CompressStream(m.remapArchive(archive, false), compression)
+}
+
+// Tar is the Tar of archive.go, with the owners translated to the ids of
+// the containers
+func (m *idMappings) Tar(path string, compression Compression) (Archive, error) {
+	return m.TarFilter(path, compression, nil)
+}
+
+// Untar is the Untar of archive.go, with the owners translated to the ids
+// of the host
+func (m *idMappings) Untar(archive io.Reader, path string) error {
+	if m == nil {
+		return Untar(archive, path)
+	}
+	if archive == nil {
+		return fmt.Errorf("Empty archive")
+	}
+	stream, err := DecompressStream(archive)
+	if err != nil {
+		return err
+	}
+	return Untar(m.remapArchive(stream, true), path)
+}
+
+// UntarPath is the UntarPath of archive.go, with the owners translated to
+// the ids of the host
+func (m *idMappings) UntarPath(src, dst string) error {
+	archive, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	return m.Untar(archive, dst)
+}
+
+// CopyWithTar is the CopyWithTar of archive.go, with the owners translated
+// to the ids of the host
+func (m *idMappings) CopyWithTar(src, dst string) error {
+	if m == nil {
+		return CopyWithTar(src, dst)
+	}
+	srcSt, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !srcSt.IsDir() {
+		if err := CopyFileWithTar(src, dst); err != nil {
+			return err
+		}
+		if dst[len(dst)-1] == '/' {
+			dst = path.Join(dst, filepath.Base(src))
+		}
+		stat := srcSt.Sys().(*syscall.Stat_t)
+		uid, gid, err := m.mapIDs(int(stat.Uid), int(stat.Gid), true)
+		if err != nil {
+			return err
+		}
+		if err := os.Lchown(dst, uid, gid); err != nil {
+			return err
+		}
+		return os.Chmod(dst, srcSt.Mode())
+	}
+	if err := os.MkdirAll(dst, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	archive, err := TarFilter(src, Uncompressed, nil)
+	if err != nil {
+		return err
+	}
+	return Untar(m.remapArchive(archive, true), dst)
+}
+
+// setupUserns gives what the container needs to write to the root of its
+// user namespace. Everything else it uses is readable by anyone.
+func (container *Container) setupUserns(hostConfig *HostConfig) error {
+	m := container.runtime.idMappings
+	if m == nil {
+		return nil
+	}
+	if hostConfig.Privileged {
+		return fmt.Errorf("Conflict: privileged containers can't run with user namespace remapping")
+	}
+	paths := []string{container.root, container.rwPath(), container.InitDirPath()}
+	for _, device := range container.devices {
+		paths = append(paths, path.Join(container.RootfsPath(), device.PathInContainer))
+	}
+	return m.chownRoot(paths...)
+}
@@ -0,0 +1,168 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestParseSubIDs(t *testing.T) {
+	subuid := `
+# name:start:count
+dockremap:100000:65536
+other:200000:65536
+1000:300000:1000
+dockremap:400000:1000
+`
+	maps, err := parseSubIDs(strings.NewReader(subuid), "dockremap", "999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []idMap{{0, 100000, 65536}, {65536, 400000, 1000}}
+	if len(maps) != len(expected) || maps[0] != expected[0] || maps[1] != expected[1] {
+		t.Fatalf("Expected %v, got %v", expected, maps)
+	}
+
+	maps, err = parseSubIDs(strings.NewReader(subuid), "alice", "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 1 || maps[0] != (idMap{0, 300000, 1000}) {
+		t.Fatalf("Unexpected mappings for uid 1000: %v", maps)
+	}
+
+	if _, err := parseSubIDs(strings.NewReader("dockremap:100000:none\n"), "dockremap"); err == nil {
+		t.Fatal("An invalid range should fail")
+	}
+}
+
+func TestMapID(t *testing.T) {
+	maps := []idMap{{0, 100000, 1000}, {1000, 500000, 1000}}
+	for _, c := range []struct {
+		id, expected int
+		toHost       bool
+	}{
+		{0, 100000, true},
+		{999, 100999, true},
+		{1500, 500500, true},
+		{100042, 42, false},
+		{500000, 1000, false},
+		{0, overflowID, false},
+	} {
+		id, err := mapID(maps, c.id, c.toHost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != c.expected {
+			t.Errorf("Expected %d to map to %d, got %d", c.id, c.expected, id)
+		}
+	}
+	if _, err := mapID(maps, 2000, true); err == nil {
+		t.Fatal("Ids outside of the range should not map to the host")
+	}
+}
+
+func TestRemapArchive(t *testing.T) {
+	m := &idMappings{
+		Uids: []idMap{{0, 100000, 65536}},
+		Gids: []idMap{{0, 200000, 65536}},
+	}
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/passwd", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+		{Name: "home/user/", Typeflag: tar.TypeDir, Mode: 0700, Uid: 1000, Gid: 1000},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte("root\n"))
+		}
+	}
+	tw.Close()
+
+	remapped, err := ioutil.ReadAll(m.remapArchive(bytes.NewReader(buf.Bytes()), true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(bytes.NewReader(remapped))
+	for _, expected := range [][2]int{{100000, 200000}, {100000, 200000}, {101000, 201000}} {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Uid != expected[0] || hdr.Gid != expected[1] {
+			t.Errorf("%s: expected %d:%d, got %d:%d", hdr.Name, expected[0], expected[1], hdr.Uid, hdr.Gid)
+		}
+		if hdr.Name == "etc/passwd" {
+			if data, _ := ioutil.ReadAll(tr); string(data) != "root\n" {
+				t.Errorf("The content of etc/passwd changed: %q", data)
+			}
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Fatalf("Expected 3 entries")
+	}
+
+	// And back
+	restored, err := ioutil.ReadAll(m.remapArchive(bytes.NewReader(remapped), false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr = tar.NewReader(bytes.NewReader(restored))
+	if hdr, err := tr.Next(); err != nil || hdr.Uid != 0 || hdr.Gid != 0 {
+		t.Fatalf("Expected etc/ to belong to root again: %v %v", hdr, err)
+	}
+}
+
+func TestShiftTree(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Changing owners needs root")
+	}
+	m := &idMappings{
+		Uids: []idMap{{0, 100000, 65536}},
+		Gids: []idMap{{0, 100000, 65536}},
+	}
+	root, err := ioutil.TempDir("", "docker-test-shift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	bin := path.Join(root, "su")
+	if err := ioutil.WriteFile(bin, []byte{}, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(bin, 0755|os.ModeSetuid); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("su", path.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	// Hard links are shifted once
+	if err := os.Link(bin, path.Join(root, "hardlink")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.shiftTree(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{root, bin, path.Join(root, "link"), path.Join(root, "hardlink")} {
+		fi, err := os.Lstat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat := fi.Sys().(*syscall.Stat_t); stat.Uid != 100000 || stat.Gid != 100000 {
+			t.Errorf("%s: expected 100000:100000, got %d:%d", p, stat.Uid, stat.Gid)
+		}
+	}
+	if fi, _ := os.Stat(bin); fi.Mode()&os.ModeSetuid == 0 {
+		t.Error("The setuid bit should be kept")
+	}
+}
@@ -13,12 +13,24 @@ func CompareConfig(a, b *Config) bool {
 		a.Memory != b.Memory ||
 		a.MemorySwap != b.MemorySwap ||
 		a.CpuShares != b.CpuShares ||
+		a.MemoryReservation != b.MemoryReservation ||
+		a.CpusetCpus != b.CpusetCpus ||
+		a.CpusetMems != b.CpusetMems ||
+		a.CpuQuota != b.CpuQuota ||
+		a.CpuPeriod != b.CpuPeriod ||
+		a.BlkioWeight != b.BlkioWeight ||
+		a.PidsLimit != b.PidsLimit ||
 		a.OpenStdin != b.OpenStdin ||
-		a.Tty != b.Tty {
+		a.Tty != b.Tty ||
+		a.WorkingDir != b.WorkingDir {
 		return false
 	}
 	if len(a.Cmd) != len(b.Cmd) ||
 		len(a.Dns) != len(b.Dns) ||
+		len(a.DnsSearch) != len(b.DnsSearch) ||
+		len(a.DnsOptions) != len(b.DnsOptions) ||
+		len(a.BlkioDeviceReadBps) != len(b.BlkioDeviceReadBps) ||
+		len(a.BlkioDeviceWriteBps) != len(b.BlkioDeviceWriteBps) ||
 		len(a.Env) != len(b.Env) ||
 		len(a.PortSpecs) != len(b.PortSpecs) ||
 		len(a.Entrypoint) != len(b.Entrypoint) {
@@ -35,6 +47,26 @@ func CompareConfig(a, b *Config) bool {
 			return false
 		}
 	}
+	for i := 0; i < len(a.DnsSearch); i++ {
+		if a.DnsSearch[i] != b.DnsSearch[i] {
+			return false
+		}
+	}
+	for i := 0; i < len(a.DnsOptions); i++ {
+		if a.DnsOptions[i] != b.DnsOptions[i] {
+			return false
+		}
+	}
+	for i := 0; i < len(a.BlkioDeviceReadBps); i++ {
+		if a.BlkioDeviceReadBps[i] != b.BlkioDeviceReadBps[i] {
+			return false
+		}
+	}
+	for i := 0; i < len(a.BlkioDeviceWriteBps); i++ {
+		if a.BlkioDeviceWriteBps[i] != b.BlkioDeviceWriteBps[i] {
+			return false
+		}
+	}
 	for i := 0; i < len(a.Env); i++ {
 		if a.Env[i] != b.Env[i] {
 			return false
@@ -87,10 +119,19 @@ func MergeConfig(userConf, imageConf *Config) {
 	if userConf.Dns == nil || len(userConf.Dns) == 0 {
 		userConf.Dns = imageConf.Dns
 	}
+	if userConf.DnsSearch == nil || len(userConf.DnsSearch) == 0 {
+		userConf.DnsSearch = imageConf.DnsSearch
+	}
+	if userConf.DnsOptions == nil || len(userConf.DnsOptions) == 0 {
+		userConf.DnsOptions = imageConf.DnsOptions
+	}
 	if userConf.Entrypoint == nil || len(userConf.Entrypoint) == 0 {
 		userConf.Entrypoint = imageConf.Entrypoint
 	}
 	if userConf.Volumes == nil || len(userConf.Volumes) == 0 {
 		userConf.Volumes = imageConf.Volumes
 	}
+	if userConf.WorkingDir == "" {
+		userConf.WorkingDir = imageConf.WorkingDir
+	}
 }
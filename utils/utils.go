@@ -483,9 +483,86 @@ func FindCgroupMountpoint(cgroupType string) (string, error) {
 		}
 	}
 
+	// On a unified hierarchy, every controller lives in the same tree. The
+	// freezer and the device controller are built in.
+	if IsCgroupUnified() {
+		mountpoint, err := FindCgroup2Mountpoint()
+		if err != nil {
+			return "", err
+		}
+		switch cgroupType {
+		case "freezer", "devices":
+			return mountpoint, nil
+		case "blkio":
+			cgroupType = "io"
+		case "cpuacct":
+			cgroupType = "cpu"
+		}
+		controllers, err := ioutil.ReadFile(filepath.Join(mountpoint, "cgroup.controllers"))
+		if err != nil {
+			return "", err
+		}
+		for _, controller := range strings.Fields(string(controllers)) {
+			if controller == cgroupType {
+				return mountpoint, nil
+			}
+		}
+	}
+
 	return "", fmt.Errorf("cgroup mountpoint not found for %s", cgroupType)
 }
 
+// FindCgroup2Mountpoint returns the mountpoint of the cgroup v2 hierarchy
+func FindCgroup2Mountpoint() (string, error) {
+	output, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.Split(line, " ")
+		if len(parts) == 6 && parts[2] == "cgroup2" {
+			return parts[1], nil
+		}
+	}
+	return "", fmt.Errorf("cgroup2 mountpoint not found")
+}
+
+var cgroupUnified struct {
+	sync.Once
+	unified bool
+}
+
+// IsCgroupUnified returns true if the host only uses the cgroup v2 (unified)
+// hierarchy. Hosts which mount both keep using the v1 controllers. The
+// hierarchy is detected once, by the first call.
+func IsCgroupUnified() bool {
+	cgroupUnified.Do(func() {
+		cgroupUnified.unified = detectCgroupUnified()
+	})
+	return cgroupUnified.unified
+}
+
+func detectCgroupUnified() bool {
+	output, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return false
+	}
+	unified := false
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.Split(line, " ")
+		if len(parts) != 6 {
+			continue
+		}
+		switch parts[2] {
+		case "cgroup":
+			return false
+		case "cgroup2":
+			unified = true
+		}
+	}
+	return unified
+}
+
 func GetKernelVersion() (*KernelVersionInfo, error) {
 	var (
 		flavor               string
@@ -700,3 +777,56 @@ func ParseRepositoryTag(repos string) (string, string) {
 	}
 	return repos, ""
 }
+
+// FollowSymlinkInScope resolves the symlinks of `link`, a path under `root`,
+// as if `root` was the root of the filesystem: absolute symlinks and `..`
+// never lead outside of it. Components which don't exist are kept as they
+// are, so that the result can be created, and the components after them are
+// still resolved: `..` may lead back to existing ones.
+func FollowSymlinkInScope(link, root string) (string, error) {
+	root = filepath.Clean(root)
+	link = filepath.Clean(link)
+	rel, err := filepath.Rel(root, link)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s is not within %s", link, root)
+	}
+
+	resolved := "/"
+	pending := strings.Split(rel, "/")
+	for followed := 0; len(pending) > 0; {
+		component := pending[0]
+		pending = pending[1:]
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, component)
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if os.IsNotExist(err) {
+			resolved = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if followed++; followed > 255 {
+			return "", fmt.Errorf("Too many levels of symbolic links: %s", link)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return filepath.Join(root, resolved), nil
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -292,3 +294,57 @@ func TestParseHost(t *testing.T) {
 		t.Errorf("unix:///var/run/docker.sock -> expected unix:///var/run/docker.sock, got %s", addr)
 	}
 }
+
+func TestFollowSymlinkInScope(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-test-symlink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.MkdirAll(filepath.Join(root, "etc", "real"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"abs":       "/root",
+		"rel":       "etc/real",
+		"up":        "../../../../etc",
+		"etc/loop1": "loop2",
+		"etc/loop2": "loop1",
+		"etc/chain": "../rel",
+		"escape":    "nonexist/../evil",
+		"evil":      "/etc",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for link, expected := range map[string]string{
+		"":               "",
+		"etc/real":       "etc/real",
+		"abs":            "root",
+		"abs/.ssh/keys":  "root/.ssh/keys",
+		"rel/file":       "etc/real/file",
+		"up/passwd":      "etc/passwd",
+		"etc/chain/file": "etc/real/file",
+		"missing/../abs": "root",
+		// The components after a missing one are still resolved
+		"escape/passwd":   "etc/passwd",
+		"abs/new/../.ssh": "root/.ssh",
+	} {
+		resolved, err := FollowSymlinkInScope(filepath.Join(root, link), root)
+		if err != nil {
+			t.Fatalf("%s: %s", link, err)
+		}
+		if resolved != filepath.Join(root, expected) {
+			t.Fatalf("%s: expected %s, got %s", link, filepath.Join(root, expected), resolved)
+		}
+	}
+
+	if _, err := FollowSymlinkInScope(filepath.Join(root, "etc/loop1"), root); err == nil {
+		t.Fatal("A symlink loop should fail")
+	}
+	if _, err := FollowSymlinkInScope("/etc/passwd", root); err == nil {
+		t.Fatal("A path outside of the root should be refused")
+	}
+}
@@ -0,0 +1,189 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+)
+
+// VolumePluginDir holds the unix sockets of the out-of-process volume
+// drivers, named after them: the driver nfs listens on nfs.sock.
+var VolumePluginDir = "/run/docker/plugins"
+
+// A VolumeDriver stores volumes outside of the volume store, e.g. on NFS
+// exports. The store keeps the configuration of the volumes and asks the
+// driver for their data. Mount and Unmount are called once per container
+// using a volume, when it starts and when it stops.
+type VolumeDriver interface {
+	// Create creates a volume, with options specific to the driver
+	Create(name string, opts map[string]string) error
+
+	// Remove removes a volume and its data
+	Remove(name string) error
+
+	// Mount makes a volume available on the host and returns its path
+	Mount(name string) (string, error)
+
+	// Unmount releases a volume mounted for a container which stopped
+	Unmount(name string) error
+
+	// Path returns the path of a volume on the host, as Mount does,
+	// without mounting it
+	Path(name string) (string, error)
+}
+
+var (
+	volumeDrivers     = make(map[string]VolumeDriver)
+	volumeDriversLock sync.Mutex
+)
+
+// RegisterVolumeDriver makes a driver which runs in the daemon available
+// under the given name
+func RegisterVolumeDriver(name string, driver VolumeDriver) error {
+	volumeDriversLock.Lock()
+	defer volumeDriversLock.Unlock()
+	if _, exists := volumeDrivers[name]; exists || name == "local" {
+		return fmt.Errorf("Conflict: the volume driver %s is already registered", name)
+	}
+	volumeDrivers[name] = driver
+	return nil
+}
+
+// lookupVolumeDriver returns the driver registered under the given name, or
+// the plugin listening on its socket in VolumePluginDir
+func lookupVolumeDriver(name string) (VolumeDriver, error) {
+	volumeDriversLock.Lock()
+	defer volumeDriversLock.Unlock()
+	if driver, exists := volumeDrivers[name]; exists {
+		return driver, nil
+	}
+	socket := path.Join(VolumePluginDir, name+".sock")
+	if fi, err := os.Stat(socket); err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil, fmt.Errorf("No such volume driver: %s", name)
+	}
+	return newVolumePlugin(name, socket), nil
+}
+
+// A volumePlugin is a VolumeDriver running in another process. The daemon
+// POSTs a JSON object with the name of the volume to /VolumeDriver.<Method>
+// on its unix socket, e.g.:
+//
+//	POST /VolumeDriver.Mount
+//	{"Name": "data"}
+//
+// and the plugin answers with the path of the volume, or an error:
+//
+//	{"Mountpoint": "/mnt/nfs/data", "Err": ""}
+type volumePlugin struct {
+	name   string
+	client *http.Client
+}
+
+type volumePluginRequest struct {
+	Name string
+	Opts map[string]string `json:",omitempty"`
+}
+
+type volumePluginResponse struct {
+	Mountpoint string
+	Err        string
+}
+
+func newVolumePlugin(name, socket string) *volumePlugin {
+	return &volumePlugin{
+		name: name,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Dial: func(network, addr string) (net.Conn, error) {
+					return net.Dial("unix", socket)
+				},
+			},
+		},
+	}
+}
+
+func (p *volumePlugin) call(method string, req *volumePluginRequest) (*volumePluginResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Post("http://plugin/VolumeDriver."+method, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Error calling the volume driver %s: %s", p.name, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error from the volume driver %s: %s: %s", p.name, http.StatusText(resp.StatusCode), bytes.TrimSpace(data))
+	}
+	ret := &volumePluginResponse{}
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, fmt.Errorf("Invalid response from the volume driver %s: %s", p.name, err)
+	}
+	if ret.Err != "" {
+		return nil, fmt.Errorf("Error from the volume driver %s: %s", p.name, ret.Err)
+	}
+	return ret, nil
+}
+
+func (p *volumePlugin) Create(name string, opts map[string]string) error {
+	_, err := p.call("Create", &volumePluginRequest{Name: name, Opts: opts})
+	return err
+}
+
+func (p *volumePlugin) Remove(name string) error {
+	_, err := p.call("Remove", &volumePluginRequest{Name: name})
+	return err
+}
+
+func (p *volumePlugin) Mount(name string) (string, error) {
+	ret, err := p.call("Mount", &volumePluginRequest{Name: name})
+	if err != nil {
+		return "", err
+	}
+	return ret.Mountpoint, nil
+}
+
+func (p *volumePlugin) Unmount(name string) error {
+	_, err := p.call("Unmount", &volumePluginRequest{Name: name})
+	return err
+}
+
+func (p *volumePlugin) Path(name string) (string, error) {
+	ret, err := p.call("Path", &volumePluginRequest{Name: name})
+	if err != nil {
+		return "", err
+	}
+	return ret.Mountpoint, nil
+}
+
+// unmountVolumes releases the volumes of drivers mounted for a container
+// which stopped
+func (container *Container) unmountVolumes() {
+	for _, name := range container.VolumeNames {
+		if vol, err := container.runtime.volumes.Get(name); err == nil {
+			container.unmountVolume(vol)
+		}
+	}
+}
+
+// unmountVolume releases a volume mounted for the container, if a driver
+// stores it
+func (container *Container) unmountVolume(vol *Volume) {
+	if vol.Driver == "" {
+		return
+	}
+	if err := container.runtime.volumes.Unmount(vol); err != nil {
+		utils.Debugf("%s: Error unmounting the volume %s: %s", container.ID, vol.Name, err)
+	}
+}
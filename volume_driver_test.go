@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// stubVolumePlugin answers the calls of the daemon on a unix socket in
+// VolumePluginDir, and mounts its volumes in a temporary directory
+type stubVolumePlugin struct {
+	root     string
+	listener net.Listener
+	calls    []string
+	mounts   map[string]int
+}
+
+func newStubVolumePlugin(t *testing.T, name string) *stubVolumePlugin {
+	root, err := ioutil.TempDir("", "docker-test-volume-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", path.Join(root, name+".sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &stubVolumePlugin{root: root, listener: l, mounts: make(map[string]int)}
+	go http.Serve(l, stub)
+	return stub
+}
+
+func (stub *stubVolumePlugin) Close() {
+	stub.listener.Close()
+	os.RemoveAll(stub.root)
+}
+
+func (stub *stubVolumePlugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &volumePluginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/VolumeDriver.")
+	stub.calls = append(stub.calls, method+" "+req.Name)
+
+	resp := &volumePluginResponse{}
+	mountpoint := path.Join(stub.root, "volumes", req.Name)
+	switch method {
+	case "Create":
+		if req.Opts["fail"] != "" {
+			resp.Err = req.Opts["fail"]
+		} else if err := os.MkdirAll(mountpoint, 0755); err != nil {
+			resp.Err = err.Error()
+		}
+	case "Remove":
+		if err := os.RemoveAll(mountpoint); err != nil {
+			resp.Err = err.Error()
+		}
+	case "Mount":
+		stub.mounts[req.Name]++
+		resp.Mountpoint = mountpoint
+	case "Unmount":
+		stub.mounts[req.Name]--
+	case "Path":
+		resp.Mountpoint = mountpoint
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestVolumePlugin(t *testing.T) {
+	stub := newStubVolumePlugin(t, "stub")
+	defer stub.Close()
+	defer func(dir string) { VolumePluginDir = dir }(VolumePluginDir)
+	VolumePluginDir = stub.root
+
+	store := tempVolumeStore(t)
+	defer os.RemoveAll(store.root)
+
+	if _, err := store.Create("data", "nothing", nil); err == nil || !strings.HasPrefix(err.Error(), "No such") {
+		t.Fatalf("Creating a volume with an unknown driver should fail, got %v", err)
+	}
+	if _, err := store.Create("broken", "stub", map[string]string{"fail": "out of space"}); err == nil || !strings.Contains(err.Error(), "out of space") {
+		t.Fatalf("Expected the error of the driver, got %v", err)
+	}
+	if _, err := store.Get("broken"); err == nil {
+		t.Fatal("A volume the driver failed to create shouldn't be stored")
+	}
+
+	vol, err := store.Create("data", "stub", map[string]string{"size": "1G"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mountpoint, err := store.Mount(vol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := path.Join(stub.root, "volumes", "data"); mountpoint != expected || vol.Mountpoint != expected {
+		t.Fatalf("Expected the volume to be mounted at %s, got %s", expected, mountpoint)
+	}
+	if stub.mounts["data"] != 1 {
+		t.Fatalf("Expected the volume to be mounted once, got %d", stub.mounts["data"])
+	}
+	if err := store.Unmount(vol); err != nil {
+		t.Fatal(err)
+	}
+	if stub.mounts["data"] != 0 {
+		t.Fatalf("Expected the volume to be unmounted, got %d mounts", stub.mounts["data"])
+	}
+
+	// The driver and its options are kept on disk
+	store, err = NewVolumeStore(store.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := store.Get("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Driver != "stub" || reloaded.Options["size"] != "1G" || reloaded.Mountpoint != mountpoint {
+		t.Fatalf("Unexpected reloaded volume: %v", reloaded)
+	}
+
+	if err := store.Remove("data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(mountpoint); !os.IsNotExist(err) {
+		t.Fatalf("The driver wasn't asked to remove the volume: %v", err)
+	}
+	expected := "Create broken,Create data,Path data,Mount data,Unmount data,Remove data"
+	if calls := strings.Join(stub.calls, ","); calls != expected {
+		t.Fatalf("Expected the calls %s, got %s", expected, calls)
+	}
+}
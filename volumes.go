@@ -0,0 +1,461 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dotcloud/docker/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Volumes are directories of the host which outlive the containers using
+// them. Each one is stored in a directory named after it, with its data in
+// a layer subdirectory: that's where the anonymous volumes created before
+// the volume store, as images of a graph, keep theirs. The data of the
+// volumes of a VolumeDriver is wherever the driver mounts it.
+
+var validVolumeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+type Volume struct {
+	Name       string
+	Mountpoint string // Directory of the host mounted in the containers
+	Created    time.Time
+	Anonymous  bool     // Created for a volume of a container without a name
+	Containers []string // IDs of the containers using the volume, sorted
+
+	Driver  string            `json:",omitempty"` // Name of the VolumeDriver, empty for local volumes
+	Options map[string]string `json:",omitempty"` // Options given to the driver
+}
+
+type VolumeStore struct {
+	root       string
+	idMappings *idMappings // Set if the volumes belong to the root of user namespaces
+	volumes    map[string]*Volume
+	lock       sync.Mutex
+}
+
+// NewVolumeStore loads the volumes stored at `root`, which is created if it
+// doesn't exist
+func NewVolumeStore(root string) (*VolumeStore, error) {
+	if err := os.MkdirAll(root, 0700); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	store := &VolumeStore{
+		root:    root,
+		volumes: make(map[string]*Volume),
+	}
+	dir, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range dir {
+		if !fi.IsDir() || !validVolumeName.MatchString(fi.Name()) {
+			continue
+		}
+		vol, err := store.load(fi.Name())
+		if err != nil {
+			utils.Debugf("Failed to load volume %s: %s", fi.Name(), err)
+			continue
+		}
+		store.volumes[vol.Name] = vol
+	}
+	return store, nil
+}
+
+func (store *VolumeStore) load(name string) (*Volume, error) {
+	vol := &Volume{}
+	data, err := ioutil.ReadFile(store.configPath(name))
+	if os.IsNotExist(err) {
+		// Volumes created by the graph have no configuration
+		stat, err := os.Stat(store.layerPath(name))
+		if err != nil {
+			return nil, err
+		}
+		vol.Created, vol.Anonymous = stat.ModTime(), true
+	} else if err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(data, vol); err != nil {
+		return nil, err
+	}
+	vol.Name = name
+	if vol.Driver == "" {
+		vol.Mountpoint = store.layerPath(name)
+	}
+	return vol, nil
+}
+
+func (store *VolumeStore) configPath(name string) string {
+	return path.Join(store.root, name, "volume.json")
+}
+
+func (store *VolumeStore) layerPath(name string) string {
+	return path.Join(store.root, name, "layer")
+}
+
+// Create creates an empty volume, stored by the given driver or on the host
+// if it is empty or local. Anonymous volumes get a random name.
+func (store *VolumeStore) Create(name, driverName string, opts map[string]string) (*Volume, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.newVolume(name, driverName, opts)
+}
+
+// Acquire returns the volume with the given name, created if it doesn't
+// exist yet, or a new anonymous volume if the name is empty. The container
+// becomes one of its users at once, so that the volume can't be pruned
+// before the container is started.
+func (store *VolumeStore) Acquire(name, driverName, id string) (*Volume, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	vol, exists := store.volumes[name]
+	if !exists {
+		var err error
+		if vol, err = store.newVolume(name, driverName, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := store.setUser(vol, id, true); err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (store *VolumeStore) newVolume(name, driverName string, opts map[string]string) (*Volume, error) {
+	anonymous := name == ""
+	if anonymous {
+		name = GenerateID()
+	} else if !validVolumeName.MatchString(name) {
+		return nil, fmt.Errorf("Bad parameter: invalid volume name %s. Only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed.", name)
+	}
+	if driverName == "local" {
+		driverName = ""
+	}
+	var driver VolumeDriver
+	if driverName != "" {
+		var err error
+		if driver, err = lookupVolumeDriver(driverName); err != nil {
+			return nil, err
+		}
+	} else if len(opts) > 0 {
+		return nil, fmt.Errorf("Bad parameter: local volumes have no options")
+	}
+
+	if _, exists := store.volumes[name]; exists {
+		return nil, fmt.Errorf("Conflict: the volume %s already exists", name)
+	}
+	vol := &Volume{
+		Name:      name,
+		Created:   time.Now(),
+		Anonymous: anonymous,
+		Driver:    driverName,
+		Options:   opts,
+	}
+	if err := store.create(vol, driver); err != nil {
+		os.RemoveAll(path.Join(store.root, name))
+		return nil, err
+	}
+	store.volumes[name] = vol
+	return vol, nil
+}
+
+func (store *VolumeStore) create(vol *Volume, driver VolumeDriver) error {
+	if driver != nil {
+		if err := os.MkdirAll(path.Join(store.root, vol.Name), 0700); err != nil {
+			return err
+		}
+		if err := driver.Create(vol.Name, vol.Options); err != nil {
+			return err
+		}
+		// The driver may only know the path once the volume is mounted
+		if mountpoint, err := driver.Path(vol.Name); err == nil {
+			vol.Mountpoint = mountpoint
+		}
+		return store.save(vol)
+	}
+	vol.Mountpoint = store.layerPath(vol.Name)
+	if err := os.MkdirAll(vol.Mountpoint, 0755); err != nil {
+		return err
+	}
+	if store.idMappings != nil {
+		if err := store.idMappings.chownRoot(vol.Mountpoint); err != nil {
+			return err
+		}
+		// Remapped containers bind mount the volume from here
+		if err := os.Chmod(path.Dir(vol.Mountpoint), 0711); err != nil {
+			return err
+		}
+	}
+	return store.save(vol)
+}
+
+func (store *VolumeStore) save(vol *Volume) error {
+	data, err := json.Marshal(vol)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(store.configPath(vol.Name), data, 0600)
+}
+
+// Mount returns the path of the host where a volume is available, after
+// asking its driver to mount it
+func (store *VolumeStore) Mount(vol *Volume) (string, error) {
+	if vol.Driver == "" {
+		return vol.Mountpoint, nil
+	}
+	driver, err := lookupVolumeDriver(vol.Driver)
+	if err != nil {
+		return "", err
+	}
+	mountpoint, err := driver.Mount(vol.Name)
+	if err != nil {
+		return "", err
+	}
+	if !path.IsAbs(mountpoint) {
+		return "", fmt.Errorf("The volume driver %s mounted %s at an invalid path: %s", vol.Driver, vol.Name, mountpoint)
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if vol.Mountpoint != mountpoint {
+		vol.Mountpoint = mountpoint
+		if err := store.save(vol); err != nil {
+			return "", err
+		}
+	}
+	return mountpoint, nil
+}
+
+// Unmount tells the driver of a volume that a container stopped using it
+func (store *VolumeStore) Unmount(vol *Volume) error {
+	if vol.Driver == "" {
+		return nil
+	}
+	driver, err := lookupVolumeDriver(vol.Driver)
+	if err != nil {
+		return err
+	}
+	return driver.Unmount(vol.Name)
+}
+
+// remove deletes the data of a volume, then its configuration
+func (store *VolumeStore) remove(vol *Volume) error {
+	if vol.Driver != "" {
+		driver, err := lookupVolumeDriver(vol.Driver)
+		if err != nil {
+			return err
+		}
+		if err := driver.Remove(vol.Name); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(path.Join(store.root, vol.Name)); err != nil {
+		return err
+	}
+	delete(store.volumes, vol.Name)
+	return nil
+}
+
+// Get returns the volume with the given name
+func (store *VolumeStore) Get(name string) (*Volume, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if vol, exists := store.volumes[name]; exists {
+		return vol, nil
+	}
+	return nil, fmt.Errorf("No such volume: %s", name)
+}
+
+// List returns all the volumes, sorted by name
+func (store *VolumeStore) List() []*Volume {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	names := []string{}
+	for name := range store.volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	volumes := []*Volume{}
+	for _, name := range names {
+		volumes = append(volumes, store.volumes[name])
+	}
+	return volumes
+}
+
+// Remove deletes a volume and its data. Volumes used by containers, running
+// or not, can't be removed.
+func (store *VolumeStore) Remove(name string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	vol, exists := store.volumes[name]
+	if !exists {
+		return fmt.Errorf("No such volume: %s", name)
+	}
+	if users := store.users(name); len(users) > 0 {
+		for i := range users {
+			users[i] = utils.TruncateID(users[i])
+		}
+		return fmt.Errorf("Conflict: the volume %s is used by the containers %s", name, strings.Join(users, ", "))
+	}
+	return store.remove(vol)
+}
+
+// Users returns the IDs of the containers using a volume
+func (store *VolumeStore) Users(name string) []string {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.users(name)
+}
+
+func (store *VolumeStore) users(name string) []string {
+	users := []string{}
+	if vol, exists := store.volumes[name]; exists {
+		users = append(users, vol.Containers...)
+	}
+	return users
+}
+
+// VolumeNames returns the names of the volumes of the store mounted in a
+// container, by path in the container. The containers created before the
+// names were recorded only know the host paths of their volumes: the volumes
+// are found from them, as long as no driver mounted them elsewhere since.
+func (store *VolumeStore) VolumeNames(container *Container) map[string]string {
+	if container.VolumeNames != nil {
+		return container.VolumeNames
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	names := make(map[string]string)
+	for volPath, src := range container.Volumes {
+		for _, vol := range store.volumes {
+			if vol.Mountpoint == src {
+				names[volPath] = vol.Name
+			}
+		}
+	}
+	return names
+}
+
+// Reference records which volumes the container uses, from the names of its
+// volumes. The references are saved with the volumes, so that they survive
+// the containers which are removed while the daemon is down.
+func (store *VolumeStore) Reference(container *Container) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	used := make(map[string]bool)
+	for _, name := range container.VolumeNames {
+		used[name] = true
+	}
+	for name, vol := range store.volumes {
+		if err := store.setUser(vol, container.ID, used[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release forgets the volumes used by a container
+func (store *VolumeStore) Release(container *Container) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, vol := range store.volumes {
+		if err := store.setUser(vol, container.ID, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseUnknown forgets the references of the containers which are not in
+// the given list, i.e. which were removed without releasing their volumes
+func (store *VolumeStore) ReleaseUnknown(containers []*Container) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	known := make(map[string]bool)
+	for _, container := range containers {
+		known[container.ID] = true
+	}
+	for _, vol := range store.volumes {
+		for _, id := range vol.Containers {
+			if known[id] {
+				continue
+			}
+			if err := store.setUser(vol, id, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setUser adds or removes a container from the users of a volume, and saves
+// the volume if they changed
+func (store *VolumeStore) setUser(vol *Volume, id string, uses bool) error {
+	users := []string{}
+	found := false
+	for _, user := range vol.Containers {
+		if user == id {
+			found = true
+			if !uses {
+				continue
+			}
+		}
+		users = append(users, user)
+	}
+	if found == uses {
+		return nil
+	}
+	if uses {
+		users = append(users, id)
+		sort.Strings(users)
+	}
+	vol.Containers = users
+	return store.save(vol)
+}
+
+// Prune removes the volumes which no container uses. It returns their names
+// and the space they took on the host.
+func (store *VolumeStore) Prune() ([]string, int64, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	names := []string{}
+	for name, vol := range store.volumes {
+		if len(vol.Containers) == 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	deleted := []string{}
+	var reclaimed int64
+	for _, name := range names {
+		vol := store.volumes[name]
+		var size int64
+		if vol.Driver == "" {
+			size = dirSize(vol.Mountpoint)
+		}
+		if err := store.remove(vol); err != nil {
+			return deleted, reclaimed, err
+		}
+		deleted = append(deleted, name)
+		reclaimed += size
+	}
+	return deleted, reclaimed, nil
+}
+
+// dirSize returns the size of the files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, fileInfo os.FileInfo, err error) error {
+		if fileInfo != nil && !fileInfo.IsDir() {
+			size += fileInfo.Size()
+		}
+		return nil
+	})
+	return size
+}
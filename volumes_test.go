@@ -0,0 +1,256 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func tempVolumeStore(t *testing.T) *VolumeStore {
+	tmp, err := ioutil.TempDir("", "docker-test-volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewVolumeStore(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestVolumeStoreCreate(t *testing.T) {
+	store := tempVolumeStore(t)
+	defer os.RemoveAll(store.root)
+
+	vol, err := store.Create("data", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vol.Name != "data" || vol.Anonymous {
+		t.Fatalf("Unexpected volume: %v", vol)
+	}
+	if fi, err := os.Stat(vol.Mountpoint); err != nil || !fi.IsDir() {
+		t.Fatalf("The mountpoint of the volume wasn't created: %v", err)
+	}
+	if _, err := store.Create("data", "", nil); err == nil || !strings.HasPrefix(err.Error(), "Conflict") {
+		t.Fatalf("Creating an existing volume should conflict, got %v", err)
+	}
+	for _, name := range []string{"a", "-data", "da/ta", "../data"} {
+		if _, err := store.Create(name, "", nil); err == nil {
+			t.Fatalf("Creating the volume %s should fail", name)
+		}
+	}
+
+	anonymous, err := store.Create("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !anonymous.Anonymous || len(anonymous.Name) != 64 {
+		t.Fatalf("Unexpected anonymous volume: %v", anonymous)
+	}
+
+	if vol, err := store.Get("data"); err != nil || vol.Name != "data" {
+		t.Fatalf("Unable to get the volume: %v", err)
+	}
+	if _, err := store.Get("nothing"); err == nil {
+		t.Fatal("Getting a volume which doesn't exist should fail")
+	}
+	if volumes := store.List(); len(volumes) != 2 {
+		t.Fatalf("Expected 2 volumes, got %d", len(volumes))
+	}
+}
+
+func TestVolumeStoreReload(t *testing.T) {
+	store := tempVolumeStore(t)
+	defer os.RemoveAll(store.root)
+
+	vol, err := store.Create("data", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Volumes created by the graph of former versions have no configuration
+	if err := os.MkdirAll(path.Join(store.root, "0123456789ab", "layer"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewVolumeStore(store.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := store.Get("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Mountpoint != vol.Mountpoint || reloaded.Anonymous || !reloaded.Created.Equal(vol.Created) {
+		t.Fatalf("Expected %v, got %v", vol, reloaded)
+	}
+	if old, err := store.Get("0123456789ab"); err != nil || !old.Anonymous {
+		t.Fatalf("The volume of the graph wasn't loaded as anonymous: %v", err)
+	}
+}
+
+func TestVolumeStoreRemove(t *testing.T) {
+	store := tempVolumeStore(t)
+	defer os.RemoveAll(store.root)
+
+	vol, err := store.Create("data", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := &Container{
+		ID:          GenerateID(),
+		Volumes:     map[string]string{"/data": vol.Mountpoint, "/host": "/tmp"},
+		VolumeNames: map[string]string{"/data": "data"},
+	}
+	store.Reference(container)
+	if users := store.Users("data"); len(users) != 1 || users[0] != container.ID {
+		t.Fatalf("Expected the volume to be used by %s, got %v", container.ID, users)
+	}
+	if err := store.Remove("data"); err == nil || !strings.HasPrefix(err.Error(), "Conflict") {
+		t.Fatalf("Removing a volume in use should conflict, got %v", err)
+	}
+
+	store.Release(container)
+	if err := store.Remove("data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(vol.Mountpoint); !os.IsNotExist(err) {
+		t.Fatalf("The data of the volume wasn't removed: %v", err)
+	}
+	if err := store.Remove("data"); err == nil || !strings.HasPrefix(err.Error(), "No such") {
+		t.Fatalf("Removing a volume twice should fail, got %v", err)
+	}
+}
+
+func TestVolumeStoreReferences(t *testing.T) {
+	store := tempVolumeStore(t)
+	defer os.RemoveAll(store.root)
+
+	vol, err := store.Create("data", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	running := &Container{ID: GenerateID(), VolumeNames: map[string]string{"/data": vol.Name}}
+	removed := &Container{ID: GenerateID(), VolumeNames: map[string]string{"/data": vol.Name}}
+	for _, container := range []*Container{running, removed} {
+		if err := store.Reference(container); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The references are kept on disk
+	store, err = NewVolumeStore(store.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users := store.Users("data"); len(users) != 2 {
+		t.Fatalf("Expected the references to be reloaded, got %v", users)
+	}
+
+	if err := store.ReleaseUnknown([]*Container{running}); err != nil {
+		t.Fatal(err)
+	}
+	if users := store.Users("data"); len(users) != 1 || users[0] != running.ID {
+		t.Fatalf("Expected the volume to be used by %s only, got %v", running.ID, users)
+	}
+}
+
+func TestVolumeStoreAcquire(t *testing.T) {
+	store := tempVolumeStore(t)
+	defer os.RemoveAll(store.root)
+
+	id := GenerateID()
+	anonymous, err := store.Acquire("", "", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	named, err := store.Acquire("data", "", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !anonymous.Anonymous || named.Anonymous {
+		t.Fatalf("Expected only the volume without a name to be anonymous")
+	}
+	if vol, err := store.Acquire("data", "", GenerateID()); err != nil || vol != named {
+		t.Fatalf("Expected the existing volume to be acquired, got %v, %v", vol, err)
+	}
+
+	// The volumes are in use before the container references them
+	deleted, _, err := store.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 0 {
+		t.Fatalf("Expected the acquired volumes to be kept, got %v deleted", deleted)
+	}
+	if users := store.Users(anonymous.Name); len(users) != 1 || users[0] != id {
+		t.Fatalf("Expected the volume to be used by %s, got %v", id, users)
+	}
+	if users := store.Users("data"); len(users) != 2 {
+		t.Fatalf("Expected the volume to be used by both containers, got %v", users)
+	}
+}
+
+func TestVolumeStoreVolumeNames(t *testing.T) {
+	store := tempVolumeStore(t)
+	defer os.RemoveAll(store.root)
+
+	vol, err := store.Create("data", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Containers of former versions only recorded the host paths
+	container := &Container{
+		ID:      GenerateID(),
+		Volumes: map[string]string{"/data": vol.Mountpoint, "/host": "/tmp"},
+	}
+	names := store.VolumeNames(container)
+	if len(names) != 1 || names["/data"] != "data" {
+		t.Fatalf("Expected the volume to be found from its mountpoint, got %v", names)
+	}
+
+	container.VolumeNames = map[string]string{"/other": "other"}
+	if names := store.VolumeNames(container); len(names) != 1 || names["/other"] != "other" {
+		t.Fatalf("Expected the recorded names to be kept, got %v", names)
+	}
+}
+
+func TestVolumeStorePrune(t *testing.T) {
+	store := tempVolumeStore(t)
+	defer os.RemoveAll(store.root)
+
+	used, err := store.Create("used", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := store.Create("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(orphan.Mountpoint, "data"), make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	container := &Container{ID: GenerateID(), VolumeNames: map[string]string{"/data": used.Name}}
+	if err := store.Reference(container); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, reclaimed, err := store.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != orphan.Name {
+		t.Fatalf("Expected %s to be deleted, got %v", orphan.Name, deleted)
+	}
+	if reclaimed < 4096 {
+		t.Fatalf("Expected at least 4096 bytes to be reclaimed, got %d", reclaimed)
+	}
+	if _, err := os.Stat(orphan.Mountpoint); !os.IsNotExist(err) {
+		t.Fatalf("The data of the volume wasn't removed: %v", err)
+	}
+	if _, err := store.Get("used"); err != nil {
+		t.Fatal(err)
+	}
+}